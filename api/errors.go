@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+)
+
+// ErrorResponse represents the JSON body returned for failed requests
+type ErrorResponse struct {
+	Error        string                     `json:"error"`
+	Code         string                     `json:"code"`
+	InvalidFiles []*merger.InvalidFileError `json:"invalidFiles,omitempty"`
+	Result       *merger.MergeResult        `json:"result,omitempty"` // Partial merge result, e.g. skipped files
+}
+
+// Error codes returned in ErrorResponse.Code
+const (
+	codeBadRequest       = "bad_request"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeNotFound         = "not_found"
+	codeNotDirectory     = "not_directory"
+	codeNoInputFiles     = "no_input_files"
+	codeNoValidFiles     = "no_valid_files"
+	codeUnsupportedType  = "unsupported_file_type"
+	codeInvalidFile      = "invalid_file"
+	codeMergeFailed      = "merge_failed"
+	codeInvalidTempDir   = "invalid_temp_dir"
+	codeInvalidOptions   = "invalid_options"
+	codeOutputExists     = "output_exists"
+	codeTimeout          = "merge_timeout"
+	codeCanceled         = "canceled"
+	codeInternal         = "internal_error"
+)
+
+// statusClientClosedRequest is reported, and logged by proxies, when the client disconnected before the response
+const statusClientClosedRequest = 499
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a JSON error response for request-level errors
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message, Code: code})
+}
+
+// writeMethodNotAllowed writes the JSON error response for unsupported HTTP methods
+func writeMethodNotAllowed(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
+}
+
+// writeMergerError maps an error returned by pkg/merger to an HTTP status and writes a JSON error response
+func writeMergerError(w http.ResponseWriter, prefix string, err error, result *merger.MergeResult) {
+	status, code := statusForError(err)
+	if result != nil && len(result.SkippedFiles) == 0 && len(result.Warnings) == 0 {
+		result = nil
+	}
+	writeJSON(w, status, ErrorResponse{
+		Error:        prefix + err.Error(),
+		Code:         code,
+		InvalidFiles: merger.InvalidFiles(err),
+		Result:       result,
+	})
+}
+
+// statusForError returns the HTTP status and error code for an error returned by pkg/merger
+func statusForError(err error) (int, string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, codeTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, codeCanceled
+	case errors.Is(err, merger.ErrInputNotFound), errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, merger.ErrInputNotDir):
+		return http.StatusBadRequest, codeNotDirectory
+	case errors.Is(err, merger.ErrNoInputFiles):
+		return http.StatusBadRequest, codeNoInputFiles
+	case errors.Is(err, merger.ErrUnsupportedFileType):
+		return http.StatusBadRequest, codeUnsupportedType
+	case errors.Is(err, merger.ErrInvalidTempDir):
+		return http.StatusBadRequest, codeInvalidTempDir
+	case errors.Is(err, merger.ErrInvalidOptions):
+		return http.StatusBadRequest, codeInvalidOptions
+	case errors.Is(err, merger.ErrOutputExists):
+		return http.StatusConflict, codeOutputExists
+	case errors.Is(err, merger.ErrInvalidFile):
+		return http.StatusUnprocessableEntity, codeInvalidFile
+	case errors.Is(err, merger.ErrNoValidFiles):
+		return http.StatusUnprocessableEntity, codeNoValidFiles
+	case errors.Is(err, merger.ErrMergeFailed):
+		return http.StatusUnprocessableEntity, codeMergeFailed
+	}
+	return http.StatusInternalServerError, codeInternal
+}
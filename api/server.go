@@ -1,29 +1,78 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/liliang-cn/pdf-merger/pkg/merger"
 )
 
 // MergeRequest represents the JSON structure for a PDF merge request
 type MergeRequest struct {
-	InputDir   string `json:"inputDir"`
-	OutputFile string `json:"outputFile"`
+	InputDir    string   `json:"inputDir"`
+	OutputFile  string   `json:"outputFile"`
+	IfExists    string   `json:"ifExists,omitempty"`   // When the output file exists: overwrite (default), error or skip
+	Validation  string   `json:"validation,omitempty"` // Validation mode: strict or relaxed (default)
+	Repair      bool     `json:"repair,omitempty"`
+	SkipInvalid bool     `json:"skipInvalid,omitempty"`
+	Append      bool     `json:"append,omitempty"`   // Add the files to the existing output file with an incremental update
+	InsertAt    int      `json:"insertAt,omitempty"` // With append, page the files are inserted before, 0 adds them at the end
+	Order       string   `json:"order,omitempty"`    // File order: alphanumeric (default), natural or none
+	Include     []string `json:"include,omitempty"`  // Glob patterns, only matching file names are merged
+	Exclude     []string `json:"exclude,omitempty"`  // Glob patterns, matching file names are left out
 }
 
 // MergeMdRequest represents the JSON structure for a Markdown merge request
 type MergeMdRequest struct {
-	InputDir   string `json:"inputDir"`
-	OutputFile string `json:"outputFile"`
-	AddTitles  bool   `json:"addTitles"`
+	InputDir     string   `json:"inputDir"`
+	Summary      string   `json:"summary,omitempty"` // SUMMARY.md file listing the files to merge in order, replaces inputDir
+	OutputFile   string   `json:"outputFile"`
+	IfExists     string   `json:"ifExists,omitempty"` // When the output file exists: overwrite (default), error or skip
+	AddTitles    bool     `json:"addTitles"`
+	Order        string   `json:"order,omitempty"`        // File order: alphanumeric (default), natural or none
+	Include      []string `json:"include,omitempty"`      // Glob patterns, only matching file names are merged
+	Exclude      []string `json:"exclude,omitempty"`      // Glob patterns, matching file names are left out
+	Format       string   `json:"format,omitempty"`       // markdown, html or epub, from the output file extension when empty
+	Title        string   `json:"title,omitempty"`        // HTML page or EPUB book title
+	InlineImages bool     `json:"inlineImages,omitempty"` // Embed local images in the HTML page as data URIs
+	Authors      []string `json:"authors,omitempty"`      // EPUB book authors
+	Language     string   `json:"language,omitempty"`     // EPUB book language, defaults to en
+	Cover        string   `json:"cover,omitempty"`        // EPUB cover image file
+	Manifest     string   `json:"manifest,omitempty"`     // YAML file with the EPUB book metadata, the fields above override it
+	NoIncludes   bool     `json:"noIncludes,omitempty"`   // Leave include directives in the files as they are
+	IncludeDepth int      `json:"includeDepth,omitempty"` // Deepest nesting of includes, defaults to 8
+	Encoding     string   `json:"encoding,omitempty"`     // Encoding of the input files, detected for each file when empty
+	LineEndings  string   `json:"lineEndings,omitempty"`  // lf or crlf, the line endings of the inputs are kept when empty
+	Header       string   `json:"header,omitempty"`       // Go template written before each file instead of the title
+	Footer       string   `json:"footer,omitempty"`       // Go template written after each file
+	Separator    string   `json:"separator,omitempty"`    // Go template written between files
+}
+
+// MergeTextRequest represents the JSON structure for a request to merge plain text, CSV or source code files
+type MergeTextRequest struct {
+	InputDir    string   `json:"inputDir"`
+	Files       []string `json:"files,omitempty"` // Files to merge in the given order, replaces inputDir
+	OutputFile  string   `json:"outputFile"`
+	IfExists    string   `json:"ifExists,omitempty"`    // When the output file exists: overwrite (default), error or skip
+	Format      string   `json:"format,omitempty"`      // text, csv or code, from the output file extension when empty
+	NoBanners   bool     `json:"noBanners,omitempty"`   // Leave out the lines naming each file
+	Order       string   `json:"order,omitempty"`       // File order: alphanumeric (default), natural or none
+	Include     []string `json:"include,omitempty"`     // Glob patterns, only matching file names are merged
+	Exclude     []string `json:"exclude,omitempty"`     // Glob patterns, matching file names are left out
+	Encoding    string   `json:"encoding,omitempty"`    // Encoding of the input files, detected for each file when empty
+	LineEndings string   `json:"lineEndings,omitempty"` // lf or crlf, the line endings of the inputs are kept when empty
 }
 
 // TempDirRequest represents the JSON structure for a new temporary directory request
@@ -40,33 +89,96 @@ type FileUploadInfo struct {
 
 // MergeFilesRequest represents the request structure for merging uploaded files
 type MergeFilesRequest struct {
-	TempDir    string   `json:"tempDir"`
-	FileNames  []string `json:"fileNames,omitempty"` // Optional list of filenames, if empty use all files in directory
-	OutputFile string   `json:"outputFile"`
-	AddTitles  bool     `json:"addTitles,omitempty"` // Only for Markdown files
+	TempDir     string   `json:"tempDir"`
+	FileNames   []string `json:"fileNames,omitempty"` // Optional list of filenames, if empty use all files in directory
+	OutputFile  string   `json:"outputFile"`
+	Format      string   `json:"format,omitempty"`      // Output format, from the output file extension or the first file when empty
+	IfExists    string   `json:"ifExists,omitempty"`    // When the output file exists: overwrite (default), error or skip
+	AddTitles   bool     `json:"addTitles,omitempty"`   // Only for Markdown files
+	NoBanners   bool     `json:"noBanners,omitempty"`   // Only for text and source code files
+	Validation  string   `json:"validation,omitempty"`  // Only for PDF files: strict or relaxed (default)
+	Repair      bool     `json:"repair,omitempty"`      // Only for PDF files
+	SkipInvalid bool     `json:"skipInvalid,omitempty"` // Only for PDF files
+	Order       string   `json:"order,omitempty"`       // File order: none (default, as listed), alphanumeric or natural
+}
+
+// ExtractRequest represents the JSON structure for a request to extract pages, images or text from a PDF file
+type ExtractRequest struct {
+	File       string `json:"file"`
+	Pages      string `json:"pages,omitempty"`      // Page range expression, e.g. "1-3,8", all pages when empty
+	OutputFile string `json:"outputFile,omitempty"` // New PDF file with the selected pages
+	ImagesDir  string `json:"imagesDir,omitempty"`  // Directory for the embedded images
+	TextFile   string `json:"textFile,omitempty"`   // Text of the selected pages, JSON if it ends in .json
+}
+
+// ExportRequest represents the JSON structure for a request to export the text of a PDF file
+type ExportRequest struct {
+	File        string `json:"file"`
+	OutputFile  string `json:"outputFile,omitempty"`  // File the text is written to, it is returned in the response when empty
+	Format      string `json:"format,omitempty"`      // markdown or text, from the output file name when empty, markdown otherwise
+	Pages       string `json:"pages,omitempty"`       // Page range expression, e.g. "1-3,8", all pages when empty
+	PageMarkers *bool  `json:"pageMarkers,omitempty"` // Mark where each page starts, defaults to true
+}
+
+// DownloadZipRequest represents the request structure for downloading several files as one ZIP archive
+type DownloadZipRequest struct {
+	Files []string `json:"files,omitempty"` // Files to bundle, each stored under its base name
+	Dir   string   `json:"dir,omitempty"`   // Directory to bundle with its subdirectories, used when files is empty
+	Name  string   `json:"name,omitempty"`  // File name of the download, from the directory or download.zip when empty
 }
 
+// Config stores API server settings
+type Config struct {
+	Port             int
+	MaxMergeDuration time.Duration // Merges running longer are cancelled, 0 disables the limit
+}
+
+// DefaultMaxMergeDuration is the merge time limit used by StartServer
+const DefaultMaxMergeDuration = 10 * time.Minute
+
+// serverConfig holds the settings of the running server
+var serverConfig = Config{MaxMergeDuration: DefaultMaxMergeDuration}
+
 // StartServer starts the API server
 func StartServer(port int) error {
-	addr := fmt.Sprintf(":%d", port)
+	return StartServerWithConfig(Config{Port: port, MaxMergeDuration: DefaultMaxMergeDuration})
+}
+
+// StartServerWithConfig starts the API server with the given settings
+func StartServerWithConfig(config Config) error {
+	serverConfig = config
+	addr := fmt.Sprintf(":%d", config.Port)
 	fmt.Printf("API server started at http://localhost%s\n", addr)
 	fmt.Printf("Available endpoints:\n")
 	fmt.Printf("  POST /api/merge         - Merge PDF files\n")
 	fmt.Printf("  POST /api/merge-md      - Merge Markdown files\n")
+	fmt.Printf("  POST /api/merge-text    - Merge text, CSV or source code files\n")
 	fmt.Printf("  GET  /api/files?dir=... - List PDF files in directory\n")
 	fmt.Printf("  GET  /api/md-files?dir=... - List Markdown files in directory\n")
+	fmt.Printf("  GET  /api/info?path=... - Get PDF or Markdown file details\n")
+	fmt.Printf("  POST /api/extract       - Extract pages, images or text from a PDF file\n")
+	fmt.Printf("  POST /api/export        - Export the text of a PDF file as Markdown or plain text\n")
+	fmt.Printf("  GET  /api/download-zip?file=...|dir=... - Download files or a directory as one ZIP archive\n")
 	fmt.Printf("  POST /api/temp-dir      - Create new temporary directory\n")
 	fmt.Printf("  POST /api/upload        - Upload files to temporary directory\n")
 	fmt.Printf("  GET  /api/temp-files?dir=... - List files in temporary directory\n")
 	fmt.Printf("  POST /api/merge-files   - Merge files in temporary directory\n")
 	fmt.Printf("  DELETE /api/temp-dir    - Delete temporary directory\n")
+	if config.MaxMergeDuration > 0 {
+		fmt.Printf("Maximum merge duration: %s\n", config.MaxMergeDuration)
+	}
 
 	// Register API route handlers
 	http.HandleFunc("/api/merge", handleMerge)
 	http.HandleFunc("/api/merge-md", handleMergeMd)
+	http.HandleFunc("/api/merge-text", handleMergeText)
 	http.HandleFunc("/api/files", handleListFiles)
 	http.HandleFunc("/api/md-files", handleListMdFiles)
+	http.HandleFunc("/api/info", handleFileInfo)
+	http.HandleFunc("/api/extract", handleExtract)
+	http.HandleFunc("/api/export", handleExport)
 	http.HandleFunc("/api/download/", handleDownload)
+	http.HandleFunc("/api/download-zip", handleDownloadZip)
 	http.HandleFunc("/api/temp-dir", handleTempDir)
 	http.HandleFunc("/api/upload", handleFileUpload)
 	http.HandleFunc("/api/temp-files", handleListTempFiles)
@@ -75,21 +187,30 @@ func StartServer(port int) error {
 	return http.ListenAndServe(addr, nil)
 }
 
+// mergeContext returns the context for a merge started by r. It is cancelled when the client
+// disconnects or when the merge runs longer than the configured maximum duration.
+func mergeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if serverConfig.MaxMergeDuration > 0 {
+		return context.WithTimeout(r.Context(), serverConfig.MaxMergeDuration)
+	}
+	return context.WithCancel(r.Context())
+}
+
 // handleMerge handles PDF merge requests
 func handleMerge(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only POST method is supported")
 		return
 	}
 
 	var req MergeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON request: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
 		return
 	}
 
 	if req.InputDir == "" {
-		http.Error(w, "Input directory must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Input directory must be specified")
 		return
 	}
 
@@ -105,62 +226,227 @@ func handleMerge(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	mode, err := merger.ParseValidationMode(req.Validation)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	order, err := merger.ParseSortOrder(req.Order)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	ifExists, err := merger.ParseExistingOutput(req.IfExists)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := mergeContext(r)
+	defer cancel()
+
 	// Call core logic to merge PDFs
-	result, err := merger.MergePDFs(req.InputDir, req.OutputFile, false)
+	result, err := merger.MergeContext(ctx, merger.Options{
+		InputDir:   req.InputDir,
+		Include:    req.Include,
+		Exclude:    req.Exclude,
+		Order:      order,
+		OutputFile: req.OutputFile,
+		IfExists:   ifExists,
+		Format:     merger.FormatPDF,
+		PDF: merger.PDFOptions{
+			Validation: merger.ValidationOptions{Mode: mode, Repair: req.Repair, SkipInvalid: req.SkipInvalid},
+			Append:     req.Append,
+			InsertAt:   req.InsertAt,
+		},
+	})
 	if err != nil {
-		http.Error(w, "Failed to merge PDFs: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to merge PDFs: ", err, result)
 		return
 	}
 
 	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	writeJSON(w, http.StatusOK, result)
 }
 
 // handleListFiles handles requests to list PDF files
 func handleListFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only GET method is supported")
 		return
 	}
 
 	// Get directory parameter
 	dir := r.URL.Query().Get("dir")
 	if dir == "" {
-		http.Error(w, "Directory parameter '?dir=...' must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Directory parameter '?dir=...' must be specified")
+		return
+	}
+
+	// Get PDF files and their details in the directory
+	files, err := merger.GetPDFFilesDetailsContext(r.Context(), dir)
+	if err != nil {
+		writeMergerError(w, "Failed to get PDF files: ", err, nil)
+		return
+	}
+
+	// Return result
+	writeJSON(w, http.StatusOK, files)
+}
+
+// handleFileInfo handles requests for details of a single PDF or Markdown file
+func handleFileInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "Only GET method is supported")
+		return
+	}
+
+	// Get file path parameter
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "File parameter '?path=...' must be specified")
+		return
+	}
+
+	details, err := merger.GetFileDetailsContext(r.Context(), path)
+	if err != nil {
+		writeMergerError(w, "Failed to get file details: ", err, nil)
+		return
+	}
+
+	// Return result
+	writeJSON(w, http.StatusOK, details)
+}
+
+// handleExtract handles requests to extract pages, images or text from a PDF file
+func handleExtract(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST method is supported")
+		return
+	}
+
+	var req ExtractRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
 		return
 	}
 
-	// Get PDF files in the directory
-	files, err := merger.GetPDFFiles(dir)
+	if req.File == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "File must be specified")
+		return
+	}
+	if req.OutputFile == "" && req.ImagesDir == "" && req.TextFile == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "At least one of outputFile, imagesDir or textFile must be specified")
+		return
+	}
+
+	// Ensure output paths are absolute
+	for _, path := range []*string{&req.OutputFile, &req.ImagesDir, &req.TextFile} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			if absPath, err := filepath.Abs(*path); err == nil {
+				*path = absPath
+			}
+		}
+	}
+
+	ctx, cancel := mergeContext(r)
+	defer cancel()
+
+	result, err := merger.ExtractContext(ctx, req.File, merger.ExtractOptions{
+		Pages:      req.Pages,
+		OutputFile: req.OutputFile,
+		ImagesDir:  req.ImagesDir,
+		TextFile:   req.TextFile,
+	})
 	if err != nil {
-		http.Error(w, "Failed to get PDF files: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to extract: ", err, nil)
 		return
 	}
 
 	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(files)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleExport handles requests to export the text of a PDF file as Markdown or plain text
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST method is supported")
+		return
+	}
+
+	var req ExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
+		return
+	}
+
+	if req.File == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "File must be specified")
+		return
+	}
+	format, err := merger.ParseExportFormat(req.Format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	opts := merger.ExportOptions{Format: format, Pages: req.Pages, PageMarkers: req.PageMarkers == nil || *req.PageMarkers}
+
+	ctx, cancel := mergeContext(r)
+	defer cancel()
+
+	// Without an output file the text is the response body
+	if req.OutputFile == "" {
+		var buf bytes.Buffer
+		result, err := merger.ExportPDFStream(ctx, merger.Input{Path: req.File}, &buf, opts)
+		if err != nil {
+			writeMergerError(w, "Failed to export: ", err, nil)
+			return
+		}
+		contentType := "text/markdown; charset=utf-8"
+		if result.Format == merger.ExportText {
+			contentType = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+		return
+	}
+
+	// Ensure output file path is absolute
+	if !filepath.IsAbs(req.OutputFile) {
+		if absPath, err := filepath.Abs(req.OutputFile); err == nil {
+			req.OutputFile = absPath
+		}
+	}
+
+	result, err := merger.ExportPDFContext(ctx, req.File, req.OutputFile, opts)
+	if err != nil {
+		writeMergerError(w, "Failed to export: ", err, nil)
+		return
+	}
+
+	// Return result
+	writeJSON(w, http.StatusOK, result)
 }
 
 // handleDownload provides download for merged PDF files
 func handleDownload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only GET method is supported")
 		return
 	}
 
 	// Extract file path from URL path
 	filePath := r.URL.Path[len("/api/download/"):]
 	if filePath == "" {
-		http.Error(w, "File path must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "File path must be specified")
 		return
 	}
 
 	// Check if file exists
 	file, err := os.Open(filePath)
 	if err != nil {
-		http.Error(w, "Cannot access file: "+err.Error(), http.StatusNotFound)
+		writeError(w, http.StatusNotFound, codeNotFound, "Cannot access file: "+err.Error())
 		return
 	}
 	defer file.Close()
@@ -168,40 +454,96 @@ func handleDownload(w http.ResponseWriter, r *http.Request) {
 	// Get file information
 	fileInfo, err := file.Stat()
 	if err != nil {
-		http.Error(w, "Cannot get file information: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Cannot get file information: "+err.Error())
 		return
 	}
 
 	// Set response headers
 	w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(filePath))
-	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Type", merger.FormatForPath(filePath).MediaType())
 	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
 
 	// Write file content to response
-	_, err = io.Copy(w, file)
+	// Errors while copying cannot be reported, the response has already been started
+	io.Copy(w, file)
+}
+
+// handleDownloadZip streams a set of files, or a whole directory, as one ZIP archive
+func handleDownloadZip(w http.ResponseWriter, r *http.Request) {
+	var req DownloadZipRequest
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req = DownloadZipRequest{Files: query["file"], Dir: query.Get("dir"), Name: query.Get("name")}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
+			return
+		}
+	default:
+		writeMethodNotAllowed(w, "Only GET and POST methods are supported")
+		return
+	}
+
+	// Collect the files before anything is written, so that missing files are still reported as errors
+	var entries []merger.ZipEntry
+	var err error
+	switch {
+	case len(req.Files) > 0:
+		entries, err = merger.ZipEntriesForFiles(req.Files)
+	case req.Dir != "":
+		entries, err = merger.ZipEntriesForDir(req.Dir)
+		if req.Name == "" {
+			req.Name = filepath.Base(filepath.Clean(req.Dir)) + ".zip"
+		}
+	default:
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Files or directory must be specified")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error transferring file: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to prepare download: ", err, nil)
 		return
 	}
+
+	name := filepath.Base(req.Name)
+	if req.Name == "" {
+		name = "download.zip"
+	}
+
+	// The archive is written while the files are read, its length is not known in advance
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Content-Type", "application/zip")
+
+	// Errors while writing cannot be reported in the response, which has already been started. The connection
+	// is aborted instead, so that clients do not take a truncated archive for a complete one.
+	if err := merger.WriteZip(r.Context(), w, entries); err != nil {
+		if r.Context().Err() == nil {
+			log.Printf("Failed to write %s: %v", name, err)
+		}
+		panic(http.ErrAbortHandler)
+	}
 }
 
 // handleMergeMd handles Markdown merge requests
 func handleMergeMd(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only POST method is supported")
 		return
 	}
 
 	var req MergeMdRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON request: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
 		return
 	}
 
-	if req.InputDir == "" {
-		http.Error(w, "Input directory must be specified", http.StatusBadRequest)
+	if req.InputDir == "" && req.Summary == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Input directory or summary file must be specified")
 		return
 	}
+	if req.Summary != "" {
+		req.InputDir = ""
+	}
 
 	if req.OutputFile == "" {
 		req.OutputFile = "merged.md"
@@ -215,42 +557,205 @@ func handleMergeMd(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	order, err := merger.ParseSortOrder(req.Order)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	ifExists, err := merger.ParseExistingOutput(req.IfExists)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	format, err := merger.ParseFormat(req.Format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if format == "" {
+		format = markdownOutputFormat(req.OutputFile)
+	} else if format.InputType() != "markdown" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Markdown files can only be merged into markdown, html or epub")
+		return
+	}
+	if err := merger.CheckEncoding(req.Encoding); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	lineEndings, err := merger.ParseLineEnding(req.LineEndings)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	var book merger.EPUBOptions
+	if req.Manifest != "" {
+		if book, err = merger.ReadEPUBManifest(req.Manifest); err != nil {
+			writeMergerError(w, "Failed to read manifest: ", err, nil)
+			return
+		}
+	}
+	book = book.Override(merger.EPUBOptions{Title: req.Title, Authors: req.Authors, Language: req.Language, Cover: req.Cover})
+
+	ctx, cancel := mergeContext(r)
+	defer cancel()
+
 	// Call core logic to merge Markdown
-	result, err := merger.MergeMarkdownFiles(req.InputDir, req.OutputFile, req.AddTitles, false)
+	result, err := merger.MergeContext(ctx, merger.Options{
+		InputDir:   req.InputDir,
+		Summary:    req.Summary,
+		Include:    req.Include,
+		Exclude:    req.Exclude,
+		Order:      order,
+		OutputFile: req.OutputFile,
+		IfExists:   ifExists,
+		Format:     format,
+		Markdown: merger.MarkdownOptions{
+			AddTitles:       req.AddTitles,
+			ResolveIncludes: !req.NoIncludes,
+			IncludeDepth:    req.IncludeDepth,
+			Encoding:        req.Encoding,
+			LineEndings:     lineEndings,
+			Header:          req.Header,
+			Footer:          req.Footer,
+			Separator:       req.Separator,
+		},
+		HTML: merger.HTMLOptions{Title: req.Title, InlineImages: req.InlineImages},
+		EPUB: book,
+	})
 	if err != nil {
-		http.Error(w, "Failed to merge Markdown: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to merge Markdown: ", err, result)
 		return
 	}
 
 	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// markdownOutputFormat returns the format Markdown files are merged into, from the extension of outputFile
+func markdownOutputFormat(outputFile string) merger.Format {
+	if format := merger.FormatForPath(outputFile); format.InputType() == "markdown" {
+		return format
+	}
+	return merger.FormatMarkdown
+}
+
+// handleMergeText handles requests to merge plain text, CSV or source code files
+func handleMergeText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "Only POST method is supported")
+		return
+	}
+
+	var req MergeTextRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
+		return
+	}
+
+	if req.InputDir == "" && len(req.Files) == 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Input directory or files must be specified")
+		return
+	}
+	if len(req.Files) > 0 {
+		req.InputDir = ""
+	}
+
+	if req.OutputFile == "" {
+		req.OutputFile = "merged.txt"
+	}
+
+	// Ensure output file path is absolute
+	if !filepath.IsAbs(req.OutputFile) {
+		absPath, err := filepath.Abs(req.OutputFile)
+		if err == nil {
+			req.OutputFile = absPath
+		}
+	}
+
+	order, err := merger.ParseSortOrder(req.Order)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	ifExists, err := merger.ParseExistingOutput(req.IfExists)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	format, err := merger.ParseFormat(req.Format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if format == "" {
+		format = merger.FormatForPath(req.OutputFile)
+		if !slices.Contains([]merger.Format{merger.FormatCSV, merger.FormatCode}, format) {
+			format = merger.FormatText
+		}
+	} else if !slices.Contains([]merger.Format{merger.FormatText, merger.FormatCSV, merger.FormatCode}, format) {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Text files can only be merged into text, csv or code")
+		return
+	}
+	if err := merger.CheckEncoding(req.Encoding); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	lineEndings, err := merger.ParseLineEnding(req.LineEndings)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := mergeContext(r)
+	defer cancel()
+
+	result, err := merger.MergeContext(ctx, merger.Options{
+		InputDir:   req.InputDir,
+		Files:      req.Files,
+		Include:    req.Include,
+		Exclude:    req.Exclude,
+		Order:      order,
+		OutputFile: req.OutputFile,
+		IfExists:   ifExists,
+		Format:     format,
+		Text: merger.TextOptions{
+			Banners:     !req.NoBanners,
+			Encoding:    req.Encoding,
+			LineEndings: lineEndings,
+		},
+	})
+	if err != nil {
+		writeMergerError(w, "Failed to merge text files: ", err, result)
+		return
+	}
+
+	// Return result
+	writeJSON(w, http.StatusOK, result)
 }
 
 // handleListMdFiles handles requests to list Markdown files
 func handleListMdFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only GET method is supported")
 		return
 	}
 
 	// Get directory parameter
 	dir := r.URL.Query().Get("dir")
 	if dir == "" {
-		http.Error(w, "Directory parameter '?dir=...' must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Directory parameter '?dir=...' must be specified")
 		return
 	}
 
-	// Get Markdown files in the directory
-	files, err := merger.GetMarkdownFiles(dir)
+	// Get Markdown files and their details in the directory
+	files, err := merger.GetMarkdownFilesDetailsContext(r.Context(), dir)
 	if err != nil {
-		http.Error(w, "Failed to get Markdown files: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to get Markdown files: ", err, nil)
 		return
 	}
 
 	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(files)
+	writeJSON(w, http.StatusOK, files)
 }
 
 // handleTempDir handles creation and deletion of temporary directories
@@ -260,13 +765,12 @@ func handleTempDir(w http.ResponseWriter, r *http.Request) {
 		// Create new temporary directory
 		tempDir, err := merger.CreateTempDirectory()
 		if err != nil {
-			http.Error(w, "Failed to create temporary directory: "+err.Error(), http.StatusInternalServerError)
+			writeMergerError(w, "Failed to create temporary directory: ", err, nil)
 			return
 		}
 
 		// Return temporary directory path
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
+		writeJSON(w, http.StatusOK, map[string]string{
 			"tempDir": tempDir,
 			"message": "Temporary directory created successfully",
 		})
@@ -275,37 +779,36 @@ func handleTempDir(w http.ResponseWriter, r *http.Request) {
 		// Delete existing temporary directory
 		var req map[string]string
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON request: "+err.Error(), http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
 			return
 		}
 
 		tempDir := req["tempDir"]
 		if tempDir == "" {
-			http.Error(w, "Temporary directory path must be specified", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Temporary directory path must be specified")
 			return
 		}
 
 		// Delete directory
 		if err := merger.CleanTempDirectory(tempDir); err != nil {
-			http.Error(w, "Failed to delete temporary directory: "+err.Error(), http.StatusInternalServerError)
+			writeMergerError(w, "Failed to delete temporary directory: ", err, nil)
 			return
 		}
 
 		// Return success information
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
+		writeJSON(w, http.StatusOK, map[string]string{
 			"message": "Temporary directory successfully deleted",
 		})
 
 	default:
-		http.Error(w, "Unsupported HTTP method", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Unsupported HTTP method")
 	}
 }
 
 // handleFileUpload handles file upload to temporary directory
 func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only POST method is supported")
 		return
 	}
 
@@ -319,7 +822,7 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		var err error
 		tempDir, err = merger.CreateTempDirectory()
 		if err != nil {
-			http.Error(w, "Failed to create temporary directory: "+err.Error(), http.StatusInternalServerError)
+			writeMergerError(w, "Failed to create temporary directory: ", err, nil)
 			return
 		}
 	}
@@ -327,29 +830,28 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 	// Get uploaded file
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, "Failed to get uploaded file: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Failed to get uploaded file: "+err.Error())
 		return
 	}
 	defer file.Close()
 
-	// Verify file type (only PDF and Markdown supported)
+	// Detect the file type from the content (any registered type, e.g. PDF, Markdown, text or image),
+	// content that contradicts the extension is rejected
 	fileName := header.Filename
-	fileExt := strings.ToLower(filepath.Ext(fileName))
-
-	var fileType string
-	if fileExt == ".pdf" {
-		fileType = "pdf"
-	} else if fileExt == ".md" || fileExt == ".markdown" {
-		fileType = "markdown"
-	} else {
-		http.Error(w, "Unsupported file type, only PDF or Markdown files are allowed", http.StatusBadRequest)
+	fileType, err := merger.DetectReaderType(file, fileName)
+	if err != nil {
+		writeMergerError(w, "Failed to check uploaded file: ", err, nil)
+		return
+	}
+	if fileType == "" {
+		writeError(w, http.StatusBadRequest, codeUnsupportedType, "Unsupported file type, allowed files are "+supportedFileTypes())
 		return
 	}
 
 	// Save file
 	result, err := merger.SaveUploadedFile(file, fileName, tempDir)
 	if err != nil {
-		http.Error(w, "Failed to save uploaded file: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to save uploaded file: ", err, nil)
 		return
 	}
 
@@ -357,75 +859,81 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 	result.FileType = fileType
 
 	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	writeJSON(w, http.StatusOK, result)
 }
 
 // handleListTempFiles gets list of files in temporary directory
 func handleListTempFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only GET method is supported")
 		return
 	}
 
 	// Get temporary directory parameter
 	tempDir := r.URL.Query().Get("dir")
 	if tempDir == "" {
-		http.Error(w, "Directory parameter '?dir=...' must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Directory parameter '?dir=...' must be specified")
 		return
 	}
 
 	// Get files in directory
 	files, err := merger.ListFilesInTempDir(tempDir)
 	if err != nil {
-		http.Error(w, "Failed to get files in temporary directory: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to get files in temporary directory: ", err, nil)
 		return
 	}
 
-	// Distinguish PDF and Markdown files
-	var pdfFiles []string
-	var mdFiles []string
-
+	// Group the files by registered file type, files of no known type are only in allFiles
+	filesByType := make(map[string][]string)
 	for _, file := range files {
-		ext := strings.ToLower(filepath.Ext(file))
-		if ext == ".pdf" {
-			pdfFiles = append(pdfFiles, file)
-		} else if ext == ".md" || ext == ".markdown" {
-			mdFiles = append(mdFiles, file)
+		if fileType := merger.FileTypeForPath(file); fileType != "" {
+			filesByType[fileType] = append(filesByType[fileType], file)
 		}
 	}
 
-	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"tempDir":    tempDir,
-		"allFiles":   files,
-		"pdfFiles":   pdfFiles,
-		"mdFiles":    mdFiles,
-		"totalFiles": len(files),
+	// Return result, pdfFiles and mdFiles are kept for existing clients
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"tempDir":     tempDir,
+		"allFiles":    files,
+		"filesByType": filesByType,
+		"pdfFiles":    filesByType["pdf"],
+		"mdFiles":     filesByType["markdown"],
+		"totalFiles":  len(files),
 	})
 }
 
+// supportedFileTypes lists the registered file types for error messages, e.g. "PDF, Markdown, text or image files"
+func supportedFileTypes() string {
+	var names []string
+	for _, t := range merger.FileTypes() {
+		names = append(names, t.DisplayName)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "") + " files"
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1] + " files"
+}
+
 // handleMergeUploadedFiles handles merging of uploaded files
 func handleMergeUploadedFiles(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w, "Only POST method is supported")
 		return
 	}
 
 	var req MergeFilesRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON request: "+err.Error(), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid JSON request: "+err.Error())
 		return
 	}
 
 	if req.TempDir == "" {
-		http.Error(w, "Temporary directory must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Temporary directory must be specified")
 		return
 	}
 
 	if req.OutputFile == "" {
-		http.Error(w, "Output file name must be specified", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Output file name must be specified")
 		return
 	}
 
@@ -452,36 +960,81 @@ func handleMergeUploadedFiles(w http.ResponseWriter, r *http.Request) {
 		// Use all files in the directory
 		filesToMerge, err = merger.ListFilesInTempDir(req.TempDir)
 		if err != nil {
-			http.Error(w, "Failed to get files in temporary directory: "+err.Error(), http.StatusInternalServerError)
+			writeMergerError(w, "Failed to get files in temporary directory: ", err, nil)
 			return
 		}
 	}
 
 	if len(filesToMerge) == 0 {
-		http.Error(w, "No files found to merge", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeNoInputFiles, "No files found to merge")
 		return
 	}
 
-	// Determine file type based on extension of first file
-	fileExt := strings.ToLower(filepath.Ext(filesToMerge[0]))
+	order, err := merger.ParseSortOrder(req.Order)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	ifExists, err := merger.ParseExistingOutput(req.IfExists)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	opts := merger.Options{
+		Files:      filesToMerge,
+		Order:      order,
+		OutputFile: req.OutputFile,
+		IfExists:   ifExists,
+	}
 
-	if fileExt == ".pdf" {
-		// Merge PDF files
-		result, err = merger.MergePDFFiles(filesToMerge, req.OutputFile, false)
-	} else if fileExt == ".md" || fileExt == ".markdown" {
-		// Merge Markdown files
-		result, err = merger.MergeMarkdownFilesList(filesToMerge, req.OutputFile, req.AddTitles, false)
-	} else {
-		http.Error(w, "Unsupported file type, can only merge PDF or Markdown files", http.StatusBadRequest)
+	// Determine the output format from the request, the output file name or the content of the first file.
+	// Files of other types are converted by the format registry, e.g. images into PDF pages.
+	opts.Format, err = merger.ParseFormat(req.Format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if opts.Format == "" {
+		opts.Format = merger.FormatForPath(req.OutputFile)
+	}
+	if opts.Format == "" {
+		// A file whose content contradicts its extension is reported by the merge. Archives name no format,
+		// the first other file does, and archives alone are taken to hold PDF files.
+		for _, file := range filesToMerge {
+			fileType, _ := merger.DetectFileType(file)
+			if opts.Format = merger.FormatForFileType(fileType); opts.Format != "" || !merger.IsArchive(file) {
+				break
+			}
+		}
+		if opts.Format == "" && merger.IsArchive(filesToMerge[0]) {
+			opts.Format = merger.FormatPDF
+		}
+	}
+
+	if opts.Format.InputType() == "" {
+		writeError(w, http.StatusBadRequest, codeUnsupportedType, "Unsupported file type, allowed files are "+supportedFileTypes())
 		return
 	}
 
+	// Each format only uses its own options
+	mode, err := merger.ParseValidationMode(req.Validation)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	opts.PDF.Validation = merger.ValidationOptions{Mode: mode, Repair: req.Repair, SkipInvalid: req.SkipInvalid}
+	opts.Markdown.AddTitles = req.AddTitles
+	opts.Text.Banners = !req.NoBanners
+
+	ctx, cancel := mergeContext(r)
+	defer cancel()
+
+	result, err = merger.MergeContext(ctx, opts)
 	if err != nil {
-		http.Error(w, "Failed to merge files: "+err.Error(), http.StatusInternalServerError)
+		writeMergerError(w, "Failed to merge files: ", err, result)
 		return
 	}
 
 	// Return result
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	writeJSON(w, http.StatusOK, result)
 }
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/liliang-cn/pdf-merger/cmd/stdio"
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputFile    string
+	format        string
+	pages         string
+	noPageMarkers bool
+)
+
+// NewExportCommand creates an export subcommand
+func NewExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export <file>",
+		Short: "Export the text of a PDF file as Markdown or plain text",
+		Long: `Export the text of a PDF file, such as a merged bundle, as rough Markdown or plain text that can be searched and diffed.
+
+Lines are joined into paragraphs by their spacing and indentation. In Markdown, short paragraphs set in a larger font than the body text become headings, the largest font giving level 1, and bulleted paragraphs become list items. A marker is written before the text of each page unless --no-page-markers is given.
+
+The format is taken from --format, or from the output file name: .txt selects plain text, anything else Markdown. Use "-" as the file to read the PDF document from stdin; the text is written to stdout unless --output is given.`,
+		Example: `  pdf-merger export merged.pdf -o merged.md
+  pdf-merger export merged.pdf -o merged.txt --no-page-markers
+  pdf-merger export book.pdf --pages 10-12 | grep -n "interface"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(args[0])
+		},
+	}
+
+	// Add command line parameters
+	cmd.Flags().StringVarP(&outputFile, "output", "o", stdio.Name, "Output file, - for stdout")
+	cmd.Flags().StringVarP(&format, "format", "f", "", "Output format: markdown or text (default from the output file name, markdown for stdout)")
+	cmd.Flags().StringVarP(&pages, "pages", "p", "", "Pages to export, e.g. 1-3,5,8- (default all pages)")
+	cmd.Flags().BoolVar(&noPageMarkers, "no-page-markers", false, "Do not mark where each page starts")
+
+	return cmd
+}
+
+func runExport(file string) error {
+	exportFormat, err := merger.ParseExportFormat(format)
+	if err != nil {
+		return err
+	}
+	opts := merger.ExportOptions{Format: exportFormat, Pages: pages, PageMarkers: !noPageMarkers}
+	out := stdio.MessageWriter(outputFile)
+
+	var result *merger.ExportResult
+	switch {
+	case outputFile == stdio.Name:
+		inputs, err := stdio.Inputs([]string{file})
+		if err != nil {
+			return err
+		}
+		result, err = merger.ExportPDFStream(context.Background(), inputs[0], os.Stdout, opts)
+		if err != nil {
+			return err
+		}
+	case file == stdio.Name:
+		return fmt.Errorf("Reading from stdin (%s) needs the text written to stdout, remove --output", stdio.Name)
+	default:
+		result, err = merger.ExportPDF(file, outputFile, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Success! Exported the text of %d pages as %s to %s\n", len(result.Pages), result.Format, result.OutputFile)
+		if result.Headings > 0 {
+			fmt.Fprintf(out, "Found %d headings\n", result.Headings)
+		}
+	}
+
+	if len(result.PagesWithoutText) > 0 {
+		fmt.Fprintf(out, "No text found on pages %s, they may be scanned images\n", merger.FormatPageRanges(result.PagesWithoutText))
+	}
+	return nil
+}
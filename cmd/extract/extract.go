@@ -0,0 +1,73 @@
+package extract
+
+import (
+	"fmt"
+
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pages      string
+	outputFile string
+	imagesDir  string
+	textFile   string
+)
+
+// NewExtractCommand creates an extract subcommand
+func NewExtractCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "extract <file>",
+		Short: "Extract pages, images and text from a PDF file",
+		Long: `Extract selected pages of a PDF file into a new PDF file, write the images embedded in them to a directory, and export their text to a .txt or .json file. Pages are selected with a page range expression such as "1-3,5,8-"; all pages are used when --pages is not given.
+
+Text is only available for pages whose fonts allow it to be decoded, scanned pages have none.`,
+		Example: `  pdf-merger extract book.pdf --pages 10-12 -o chapter2.pdf
+  pdf-merger extract book.pdf --images images/
+  pdf-merger extract book.pdf --pages 1-5 --text review.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExtract(args[0])
+		},
+	}
+
+	// Add command line parameters
+	cmd.Flags().StringVarP(&pages, "pages", "p", "", "Pages to extract, e.g. 1-3,5,8- (default all pages)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the selected pages to this PDF file, in the order given by --pages")
+	cmd.Flags().StringVar(&imagesDir, "images", "", "Write the images embedded in the selected pages to this directory")
+	cmd.Flags().StringVar(&textFile, "text", "", "Write the text of the selected pages to this file, as JSON if it ends in .json, otherwise as plain text with pages separated by form feeds")
+
+	return cmd
+}
+
+func runExtract(file string) error {
+	if outputFile == "" && imagesDir == "" && textFile == "" {
+		return fmt.Errorf("Nothing to extract, use --output, --images or --text")
+	}
+
+	result, err := merger.Extract(file, merger.ExtractOptions{
+		Pages:      pages,
+		OutputFile: outputFile,
+		ImagesDir:  imagesDir,
+		TextFile:   textFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	selected := merger.FormatPageRanges(result.Pages)
+	if result.OutputFile != "" {
+		fmt.Printf("Success! Extracted pages %s to %s\n", selected, result.OutputFile)
+	}
+	if result.ImagesDir != "" {
+		fmt.Printf("Success! Extracted %d images from pages %s to %s\n", len(result.Images), selected, result.ImagesDir)
+	}
+	if result.TextFile != "" {
+		fmt.Printf("Success! Extracted the text of pages %s to %s\n", selected, result.TextFile)
+		if len(result.PagesWithoutText) > 0 {
+			fmt.Printf("No text found on pages %s, they may be scanned images\n", merger.FormatPageRanges(result.PagesWithoutText))
+		}
+	}
+	return nil
+}
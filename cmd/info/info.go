@@ -0,0 +1,140 @@
+package info
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/liliang-cn/pdf-merger/cmd/progress"
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	jsonOutput bool
+)
+
+// NewInfoCommand creates an info subcommand
+func NewInfoCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "info <file|directory>...",
+		Short: "Show PDF and Markdown file details",
+		Long:  `Show details of PDF files (page count, page sizes, version, encryption, metadata, bookmarks) and Markdown files (word count, heading outline, front matter). Directories are scanned for both file types, sorted in alphanumeric order`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInfo(args)
+		},
+	}
+
+	// Add command line parameters
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output details as JSON")
+
+	return cmd
+}
+
+func runInfo(paths []string) error {
+	var detailsList []merger.FileDetails
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("Cannot access %s: %v", path, err)
+		}
+
+		if info.IsDir() {
+			pdfDetails, err := merger.GetPDFFilesDetails(path)
+			if err != nil {
+				return err
+			}
+			mdDetails, err := merger.GetMarkdownFilesDetails(path)
+			if err != nil {
+				return err
+			}
+			detailsList = append(detailsList, pdfDetails...)
+			detailsList = append(detailsList, mdDetails...)
+			continue
+		}
+
+		details, err := merger.GetFileDetails(path)
+		if details == nil {
+			return err
+		}
+		if err != nil {
+			details.Error = err.Error()
+		}
+		detailsList = append(detailsList, *details)
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(detailsList)
+	}
+
+	for i, details := range detailsList {
+		if i > 0 {
+			fmt.Println()
+		}
+		printDetails(details)
+	}
+	return nil
+}
+
+// printDetails prints file details in a human-readable form
+func printDetails(d merger.FileDetails) {
+	fmt.Printf("%s\n", d.Path)
+	fmt.Printf("  Type:       %s\n", d.Type)
+	fmt.Printf("  Size:       %s\n", progress.FormatSize(d.Size))
+	fmt.Printf("  Modified:   %s\n", d.ModTime.Format("2006-01-02 15:04:05"))
+
+	if d.Error != "" {
+		fmt.Printf("  Error:      %s\n", d.Error)
+		return
+	}
+
+	if p := d.PDF; p != nil && p.Encrypted && p.PageCount == 0 {
+		// The pages and metadata of a file with a user password cannot be read
+		fmt.Printf("  Version:    %s\n", p.Version)
+		fmt.Printf("  Encrypted:  true, a password is needed to read pages and metadata\n")
+	} else if p != nil {
+		fmt.Printf("  Pages:      %d\n", p.PageCount)
+		sizes := make([]string, 0, len(p.PageSizes))
+		for _, size := range p.PageSizes {
+			sizes = append(sizes, fmt.Sprintf("%.0fx%.0f pt", size.Width, size.Height))
+		}
+		fmt.Printf("  Page sizes: %s\n", strings.Join(sizes, ", "))
+		fmt.Printf("  Version:    %s\n", p.Version)
+		fmt.Printf("  Encrypted:  %v\n", p.Encrypted)
+		if p.Title != "" {
+			fmt.Printf("  Title:      %s\n", p.Title)
+		}
+		if p.Author != "" {
+			fmt.Printf("  Author:     %s\n", p.Author)
+		}
+		fmt.Printf("  Bookmarks:  %d\n", p.Bookmarks)
+	}
+
+	if m := d.Markdown; m != nil {
+		fmt.Printf("  Words:      %d\n", m.WordCount)
+		if len(m.FrontMatter) > 0 {
+			fmt.Printf("  Front matter:\n")
+			keys := make([]string, 0, len(m.FrontMatter))
+			for key := range m.FrontMatter {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Printf("    %s: %v\n", key, m.FrontMatter[key])
+			}
+		}
+		if len(m.Headings) > 0 {
+			fmt.Printf("  Outline:\n")
+			for _, h := range m.Headings {
+				fmt.Printf("    %s%s\n", strings.Repeat("  ", h.Level-1), h.Text)
+			}
+		}
+	}
+}
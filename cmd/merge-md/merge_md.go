@@ -2,20 +2,47 @@ package mergemd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/liliang-cn/pdf-merger/cmd/mergecmd"
+	"github.com/liliang-cn/pdf-merger/cmd/progress"
+	"github.com/liliang-cn/pdf-merger/cmd/stdio"
 	"github.com/liliang-cn/pdf-merger/pkg/merger"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	inputDir   string
-	outputFile string
-	addTitles  bool
-	verbose    bool
-	files      []string // Added: directly specify file list
+	inputDir     string
+	outputFile   string
+	addTitles    bool
+	verbose      bool
+	files        []string // Added: directly specify file list
+	order        string
+	include      []string
+	exclude      []string
+	noProgress   bool
+	ifExists     string
+	noClobber    bool
+	watchMode    bool
+	format       string
+	title        string
+	inlineImages bool
+	includes     bool
+	includeDepth int
+	summary      string
+	encoding     string
+	lineEndings  string
+	header       string
+	footer       string
+	separator    string
+	authors      []string
+	language     string
+	manifest     string
+	cover        string
 )
 
 // NewMergeMdCommand creates merge-md subcommand
@@ -23,7 +50,17 @@ func NewMergeMdCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "merge-md",
 		Short: "Merge Markdown files",
-		Long:  `Merge all Markdown files in the specified directory, or merge the specified list of Markdown files, sorted in alphanumeric order`,
+		Long: `Merge all Markdown files in the specified directory, or merge the specified list of Markdown files, sorted in alphanumeric order.
+
+The title and the separator can be replaced with Go templates given with --header, --footer and --separator. They are executed for each file with .Index, .Count, .Title, .Name, .Path, .Level, .FrontMatter and .ModTime, the separator with the file that follows it, and written on lines of their own; the functions repeat, upper, lower and trim are available, e.g. --header '{{repeat "#" .Level}} {{or .FrontMatter.title .Title}}'.
+
+With --summary, the files are merged in the order of an mdBook or GitBook SUMMARY.md instead: each linked file is a section titled with its link text, and the headings of nested entries move down one level per nesting level.
+
+A line holding <!-- include: path --> or {{< include path >}} is replaced with the content of the named file, resolved relative to the including file. Included files may include others up to --include-depth levels, include cycles are reported as errors, and the headings of an included file are moved below the heading preceding the directive. Snippets kept in the input directory can be left out of the merge with --exclude.
+
+The merged document is written as Markdown, or as a single self-contained HTML page when the output file ends in .html or --format html is given. The page renders GitHub-flavored Markdown with an embedded stylesheet, syntax highlighting and a sidebar table of contents; local images are linked relative to the output file, or embedded with --inline-images.
+
+With an output file ending in .epub or --format epub, an EPUB 3 book is written with one chapter per file, titled with the front matter title or the file name, and the images the files refer to. Book metadata is read from a YAML manifest given with --metadata, and the --title, --author, --language and --cover flags override it.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runMergeMd()
 		},
@@ -31,70 +68,224 @@ func NewMergeMdCommand() *cobra.Command {
 
 	// Add command line parameters
 	cmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Specify input directory containing Markdown files to merge")
-	cmd.Flags().StringVarP(&outputFile, "output", "o", "merged.md", "Specify output filename")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "merged.md", "Specify output filename, - writes to stdout")
 	cmd.Flags().BoolVarP(&addTitles, "add-titles", "t", true, "Add title for each file (using filename)")
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Display detailed information")
-	cmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "Specify list of Markdown files to merge, ignores input parameter if provided") // Added: file list parameter
+	cmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "Specify list of Markdown files to merge, - reads a file from stdin, ignores input parameter if provided") // Added: file list parameter
+
+	cmd.Flags().StringVar(&summary, "summary", "", "SUMMARY.md file listing the files to merge in order, ignores input parameter if provided")
+	cmd.Flags().StringVar(&order, "order", "", "File order: alphanumeric, natural or none (default alphanumeric for directories, as listed for --files)")
+	cmd.Flags().StringSliceVar(&include, "include", []string{}, "Only merge files whose name matches one of these glob patterns, e.g. 'chapter-*.md'")
+	cmd.Flags().StringVar(&header, "header", "", "Go template written before each file instead of the title, e.g. '## {{.Index}}. {{.Title}}'")
+	cmd.Flags().StringVar(&footer, "footer", "", "Go template written after each file")
+	cmd.Flags().StringVar(&separator, "separator", "", "Go template written between files instead of a horizontal rule, e.g. '<div style=\"page-break-after: always\"></div>'")
+	cmd.Flags().StringVar(&encoding, "encoding", "auto", "Encoding of the input files, e.g. utf-8, utf-16le, gbk or windows-1252, auto detects it for each file")
+	cmd.Flags().StringVar(&lineEndings, "line-endings", "", "Line endings of the merged file: lf or crlf (default those of the input files)")
+	cmd.Flags().BoolVar(&includes, "resolve-includes", true, "Replace <!-- include: path --> and {{< include path >}} lines with the content of the files they name")
+	cmd.Flags().IntVar(&includeDepth, "include-depth", 8, "Deepest nesting of included files")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", []string{}, "Leave out files whose name matches one of these glob patterns")
+	cmd.Flags().BoolVar(&noProgress, "no-progress", false, "Do not show the progress bar (it is only shown when stdout is a terminal and --verbose is off)")
+	cmd.Flags().Bool("force", false, "Overwrite the output file if it already exists, which is the default")
+	cmd.Flags().BoolVar(&noClobber, "no-clobber", false, "Do nothing if the output file already exists, instead of overwriting it")
+	cmd.Flags().StringVar(&ifExists, "if-exists", "overwrite", "What to do when the output file already exists: overwrite, error or skip")
+	cmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Keep running and merge again when input files are added, removed, renamed or modified")
+	cmd.Flags().StringVar(&format, "format", "", "Output format: markdown, html or epub (default from the output file name)")
+	cmd.Flags().StringVar(&title, "title", "", "HTML page or EPUB book title (default the first level 1 heading for HTML, the front matter title of the first file or the output file name for EPUB)")
+	cmd.Flags().BoolVar(&inlineImages, "inline-images", false, "Embed local images in the HTML page as data URIs")
+	cmd.Flags().StringSliceVar(&authors, "author", []string{}, "EPUB book author, can be repeated")
+	cmd.Flags().StringVar(&language, "language", "", "EPUB book language, e.g. en or zh-CN (default en)")
+	cmd.Flags().StringVar(&manifest, "metadata", "", "YAML manifest with the EPUB book metadata: title, authors, language, identifier, publisher, description, date and cover")
+	cmd.Flags().StringVar(&cover, "cover", "", "EPUB cover image")
+	cmd.MarkFlagsMutuallyExclusive("force", "no-clobber", "if-exists")
 
 	return cmd
 }
 
 func runMergeMd() error {
-	var result *merger.MergeResult
-	var err error
+	sortOrder, err := merger.ParseSortOrder(order)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := resolveOutputFormat()
+	if err != nil {
+		return err
+	}
+	book, err := epubOptions()
+	if err != nil {
+		return err
+	}
+	if err := merger.CheckEncoding(encoding); err != nil {
+		return err
+	}
+	lineEnding, err := merger.ParseLineEnding(lineEndings)
+	if err != nil {
+		return err
+	}
+
+	// Replace an existing output file unless asked to keep it
+	existing, err := mergecmd.ExistingOutput(ifExists, noClobber)
+	if err != nil {
+		return err
+	}
+
+	// Print messages to stderr when the merged document is written to stdout
+	out := stdio.MessageWriter(outputFile)
+
+	if watchMode && (outputFile == stdio.Name || stdio.HasStdin(files)) {
+		return fmt.Errorf("--watch cannot be used with stdin or stdout (%s)", stdio.Name)
+	}
 
 	// Ensure output file path is absolute
-	if !filepath.IsAbs(outputFile) {
+	if outputFile != stdio.Name && !filepath.IsAbs(outputFile) {
 		absPath, err := filepath.Abs(outputFile)
 		if err != nil {
-			fmt.Printf("Warning: Unable to get absolute path: %v, will use relative path\n", err)
+			fmt.Fprintf(out, "Warning: Unable to get absolute path: %v, will use relative path\n", err)
 		} else {
 			outputFile = absPath
 		}
 	}
 
 	if verbose {
-		fmt.Printf("Output file: %s\n", outputFile)
-		fmt.Printf("Add titles: %v\n", addTitles)
+		fmt.Fprintf(out, "Output file: %s\n", outputFile)
+		fmt.Fprintf(out, "Add titles: %v\n", addTitles)
+	}
+
+	opts := merger.Options{
+		Include:    include,
+		Exclude:    exclude,
+		Order:      sortOrder,
+		OutputFile: outputFile,
+		IfExists:   existing,
+		Format:     outputFormat,
+		Verbose:    verbose,
+		Markdown: merger.MarkdownOptions{
+			AddTitles:       addTitles,
+			ResolveIncludes: includes,
+			IncludeDepth:    includeDepth,
+			Encoding:        encoding,
+			LineEndings:     lineEnding,
+			Header:          header,
+			Footer:          footer,
+			Separator:       separator,
+		},
+		HTML: merger.HTMLOptions{Title: title, InlineImages: inlineImages},
+		EPUB: book,
 	}
 
-	// Choose processing mode based on parameters: file list or directory
+	// Choose processing mode based on parameters: summary file, file list or directory
+	if summary != "" {
+		if verbose {
+			fmt.Fprintf(out, "Summary file: %s\n", summary)
+		}
+		opts.Summary = summary
+	}
 	if len(files) > 0 {
 		// Use specified file list
 		if verbose {
-			fmt.Printf("Will merge %d specified Markdown files\n", len(files))
+			fmt.Fprintf(out, "Will merge %d specified Markdown files\n", len(files))
+		}
+		// Stdin is merged in place of its argument, the other files are checked like any listed file
+		stdin, err := stdio.FileInputs(files)
+		if err != nil {
+			return err
 		}
-		result, err = merger.MergeMarkdownFilesList(files, outputFile, addTitles, verbose)
-	} else {
+		opts.Files = files
+		opts.FileInputs = stdin
+	} else if summary == "" {
 		// Use directory mode
 		// Ensure input directory path exists and is accessible
 		inputInfo, err := os.Stat(inputDir)
 		if err != nil {
 			// Try to check if it's a path issue, not file doesn't exist
 			if os.IsNotExist(err) {
-				fmt.Printf("Error: Input directory does not exist: %s\n", inputDir)
-				fmt.Println("Note: If using absolute path, ensure path is completely correct")
+				fmt.Fprintf(out, "Error: Input directory does not exist: %s\n", inputDir)
+				fmt.Fprintln(out, "Note: If using absolute path, ensure path is completely correct")
 			} else {
-				fmt.Printf("Error: Cannot access input directory: %v\n", err)
+				fmt.Fprintf(out, "Error: Cannot access input directory: %v\n", err)
 			}
 			return err
 		}
 
-		if !inputInfo.IsDir() {
-			return fmt.Errorf("%s is not a directory", inputDir)
+		// A ZIP or TAR archive is scanned like a directory
+		if !inputInfo.IsDir() && !merger.IsArchive(inputDir) {
+			return fmt.Errorf("%s is not a directory or archive", inputDir)
+		}
+		if watchMode && !inputInfo.IsDir() {
+			return fmt.Errorf("--watch cannot be used with an archive (%s)", inputDir)
 		}
 
 		if verbose {
-			fmt.Printf("Input directory: %s\n", inputDir)
+			fmt.Fprintf(out, "Input directory: %s\n", inputDir)
 		}
 
-		result, err = merger.MergeMarkdownFiles(inputDir, outputFile, addTitles, verbose)
+		opts.InputDir = inputDir
+	}
+
+	if outputFile == stdio.Name {
+		opts.OutputFile = ""
+		opts.Output = os.Stdout
+	}
+
+	if verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	report := mergecmd.Reporter{Out: out, Noun: "Markdown files"}
+	if watchMode {
+		return report.Watch(opts)
+	}
+
+	// Show a progress bar on interactive terminals, verbose output would break it up
+	var bar *progress.Bar
+	if !noProgress && !verbose && outputFile != stdio.Name && progress.IsTerminal(os.Stdout) {
+		bar = progress.NewBar(os.Stdout, merger.ProgressMerged)
+		opts.Progress = bar.Handle
+	}
+
+	result, err := merger.Merge(opts)
+	if bar != nil {
+		bar.Finish()
 	}
+	return report.PrintResult(result, err)
+}
 
+// resolveOutputFormat returns the format selected by --format, or by the extension of the output file
+func resolveOutputFormat() (merger.Format, error) {
+	f, err := merger.ParseFormat(format)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if f == "" {
+		if f = merger.FormatForPath(outputFile); f.InputType() != "markdown" {
+			return merger.FormatMarkdown, nil
+		}
+		return f, nil
+	}
+	if f.InputType() != "markdown" {
+		return "", fmt.Errorf("Unsupported format %q, merge-md writes formats made from Markdown files: %s", format, strings.Join(markdownFormats(), ", "))
 	}
+	return f, nil
+}
 
-	fmt.Printf("Success! %d Markdown files merged into: %s\n", result.MergedFiles, result.OutputPath)
-	return nil
+// markdownFormats returns the names of the registered formats that merge Markdown files
+func markdownFormats() []string {
+	var names []string
+	for _, spec := range merger.Formats() {
+		if spec.InputType == "markdown" {
+			names = append(names, string(spec.Format))
+		}
+	}
+	return names
+}
+
+// epubOptions returns the book metadata of the manifest given with --metadata, overridden by the metadata flags
+func epubOptions() (merger.EPUBOptions, error) {
+	var book merger.EPUBOptions
+	if manifest != "" {
+		var err error
+		if book, err = merger.ReadEPUBManifest(manifest); err != nil {
+			return book, err
+		}
+	}
+	return book.Override(merger.EPUBOptions{Title: title, Authors: authors, Language: language, Cover: cover}), nil
 }
@@ -0,0 +1,222 @@
+package mergetext
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/liliang-cn/pdf-merger/cmd/mergecmd"
+	"github.com/liliang-cn/pdf-merger/cmd/progress"
+	"github.com/liliang-cn/pdf-merger/cmd/stdio"
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+
+	"github.com/spf13/cobra"
+)
+
+// textFormats are the formats written by merge-text
+var textFormats = []merger.Format{merger.FormatText, merger.FormatCSV, merger.FormatCode}
+
+var (
+	inputDir    string
+	outputFile  string
+	verbose     bool
+	files       []string
+	order       string
+	include     []string
+	exclude     []string
+	noProgress  bool
+	ifExists    string
+	noClobber   bool
+	watchMode   bool
+	format      string
+	banners     bool
+	encoding    string
+	lineEndings string
+)
+
+// NewMergeTextCommand creates merge-text subcommand
+func NewMergeTextCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge-text",
+		Short: "Merge plain text, CSV or source code files",
+		Long: `Merge all text files of one kind in the specified directory, or merge the specified list of files, sorted in alphanumeric order.
+
+The kind is taken from the output file name, or from --format:
+
+  text  Plain text files such as logs (.txt, .log), each preceded by a "==> name <==" line
+  csv   CSV files, the header row of the first file is written once and every other file must start with the same header
+  code  Source files such as SQL scripts, each preceded by a comment naming it in the comment syntax of its language.
+        A directory scan only picks up files with the extension of the output file, e.g. .sql for all.sql
+
+Banners naming the files are left out with --banners=false. Input files are converted to UTF-8 like Markdown files.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMergeText()
+		},
+	}
+
+	// Add command line parameters
+	cmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Specify input directory containing the files to merge")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "merged.txt", "Specify output filename, - writes to stdout")
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Display detailed information")
+	cmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "Specify list of files to merge, - reads a file from stdin, ignores input parameter if provided")
+
+	cmd.Flags().StringVar(&format, "format", "", "Output format: text, csv or code (default from the output file name, text when unknown)")
+	cmd.Flags().BoolVar(&banners, "banners", true, "Write the name of each file before its content, in a comment for source code")
+	cmd.Flags().StringVar(&order, "order", "", "File order: alphanumeric, natural or none (default alphanumeric for directories, as listed for --files)")
+	cmd.Flags().StringSliceVar(&include, "include", []string{}, "Only merge files whose name matches one of these glob patterns, e.g. 'access-*.log'")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", []string{}, "Leave out files whose name matches one of these glob patterns")
+	cmd.Flags().StringVar(&encoding, "encoding", "auto", "Encoding of the input files, e.g. utf-8, utf-16le, gbk or windows-1252, auto detects it for each file")
+	cmd.Flags().StringVar(&lineEndings, "line-endings", "", "Line endings of the merged file: lf or crlf (default those of the input files)")
+	cmd.Flags().BoolVar(&noProgress, "no-progress", false, "Do not show the progress bar (it is only shown when stdout is a terminal and --verbose is off)")
+	cmd.Flags().Bool("force", false, "Overwrite the output file if it already exists, which is the default")
+	cmd.Flags().BoolVar(&noClobber, "no-clobber", false, "Do nothing if the output file already exists, instead of overwriting it")
+	cmd.Flags().StringVar(&ifExists, "if-exists", "overwrite", "What to do when the output file already exists: overwrite, error or skip")
+	cmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Keep running and merge again when input files are added, removed, renamed or modified")
+	cmd.MarkFlagsMutuallyExclusive("force", "no-clobber", "if-exists")
+
+	return cmd
+}
+
+func runMergeText() error {
+	sortOrder, err := merger.ParseSortOrder(order)
+	if err != nil {
+		return err
+	}
+	outputFormat, err := resolveOutputFormat()
+	if err != nil {
+		return err
+	}
+	if err := merger.CheckEncoding(encoding); err != nil {
+		return err
+	}
+	lineEnding, err := merger.ParseLineEnding(lineEndings)
+	if err != nil {
+		return err
+	}
+
+	// Replace an existing output file unless asked to keep it
+	existing, err := mergecmd.ExistingOutput(ifExists, noClobber)
+	if err != nil {
+		return err
+	}
+
+	// Print messages to stderr when the merged document is written to stdout
+	out := stdio.MessageWriter(outputFile)
+
+	if watchMode && (outputFile == stdio.Name || stdio.HasStdin(files)) {
+		return fmt.Errorf("--watch cannot be used with stdin or stdout (%s)", stdio.Name)
+	}
+
+	// Ensure output file path is absolute
+	if outputFile != stdio.Name && !filepath.IsAbs(outputFile) {
+		absPath, err := filepath.Abs(outputFile)
+		if err != nil {
+			fmt.Fprintf(out, "Warning: Unable to get absolute path: %v, will use relative path\n", err)
+		} else {
+			outputFile = absPath
+		}
+	}
+
+	if verbose {
+		fmt.Fprintf(out, "Output file: %s\n", outputFile)
+		fmt.Fprintf(out, "Output format: %s\n", outputFormat)
+	}
+
+	opts := merger.Options{
+		Include:    include,
+		Exclude:    exclude,
+		Order:      sortOrder,
+		OutputFile: outputFile,
+		IfExists:   existing,
+		Format:     outputFormat,
+		Verbose:    verbose,
+		Text: merger.TextOptions{
+			Banners:     banners,
+			Encoding:    encoding,
+			LineEndings: lineEnding,
+		},
+	}
+
+	// Choose processing mode based on parameters: file list or directory
+	if len(files) > 0 {
+		if verbose {
+			fmt.Fprintf(out, "Will merge %d specified files\n", len(files))
+		}
+		// Stdin is merged in place of its argument, the other files are checked like any listed file
+		stdin, err := stdio.FileInputs(files)
+		if err != nil {
+			return err
+		}
+		opts.Files = files
+		opts.FileInputs = stdin
+	} else {
+		inputInfo, statErr := os.Stat(inputDir)
+		if statErr != nil {
+			if os.IsNotExist(statErr) {
+				fmt.Fprintf(out, "Error: Input directory does not exist: %s\n", inputDir)
+			} else {
+				fmt.Fprintf(out, "Error: Cannot access input directory: %v\n", statErr)
+			}
+			return statErr
+		}
+		// A ZIP or TAR archive is scanned like a directory
+		if !inputInfo.IsDir() && !merger.IsArchive(inputDir) {
+			return fmt.Errorf("%s is not a directory or archive", inputDir)
+		}
+		if watchMode && !inputInfo.IsDir() {
+			return fmt.Errorf("--watch cannot be used with an archive (%s)", inputDir)
+		}
+
+		if verbose {
+			fmt.Fprintf(out, "Input directory: %s\n", inputDir)
+		}
+		opts.InputDir = inputDir
+	}
+
+	if outputFile == stdio.Name {
+		opts.OutputFile = ""
+		opts.Output = os.Stdout
+	}
+
+	if verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	report := mergecmd.Reporter{Out: out, Noun: "files"}
+	if watchMode {
+		return report.Watch(opts)
+	}
+
+	// Show a progress bar on interactive terminals, verbose output would break it up
+	var bar *progress.Bar
+	if !noProgress && !verbose && outputFile != stdio.Name && progress.IsTerminal(os.Stdout) {
+		bar = progress.NewBar(os.Stdout, merger.ProgressMerged)
+		opts.Progress = bar.Handle
+	}
+
+	result, err := merger.Merge(opts)
+	if bar != nil {
+		bar.Finish()
+	}
+	return report.PrintResult(result, err)
+}
+
+// resolveOutputFormat returns the format selected by --format, or by the extension of the output file
+func resolveOutputFormat() (merger.Format, error) {
+	f, err := merger.ParseFormat(format)
+	if err != nil {
+		return "", err
+	}
+	if f == "" {
+		if f = merger.FormatForPath(outputFile); !slices.Contains(textFormats, f) {
+			return merger.FormatText, nil
+		}
+		return f, nil
+	}
+	if !slices.Contains(textFormats, f) {
+		return "", fmt.Errorf("Unsupported format %q, merge-text writes text, csv or code", format)
+	}
+	return f, nil
+}
@@ -2,19 +2,35 @@ package merge
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 
+	"github.com/liliang-cn/pdf-merger/cmd/mergecmd"
+	"github.com/liliang-cn/pdf-merger/cmd/progress"
+	"github.com/liliang-cn/pdf-merger/cmd/stdio"
 	"github.com/liliang-cn/pdf-merger/pkg/merger"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	inputDir   string
-	outputFile string
-	verbose    bool
-	files      []string // Added: directly specify file list
+	inputDir    string
+	outputFile  string
+	verbose     bool
+	files       []string // Added: directly specify file list
+	order       string
+	include     []string
+	exclude     []string
+	validation  string
+	repair      bool
+	skipInvalid bool
+	noProgress  bool
+	ifExists    string
+	noClobber   bool
+	watchMode   bool
+	appendMode  bool
+	insertAt    int
 )
 
 // NewMergeCommand creates a merge subcommand
@@ -22,7 +38,7 @@ func NewMergeCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "merge",
 		Short: "Merge PDF files",
-		Long:  `Merge all PDF files in the specified directory, or merge the specified list of PDF files, sorted in alphanumeric order`,
+		Long:  `Merge all PDF files in the specified directory, or merge the specified list of PDF files, sorted in alphanumeric order. Images (PNG, JPEG, TIFF, WebP) given with --files are converted to PDF pages and merged with them`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runMerge()
 		},
@@ -30,68 +46,155 @@ func NewMergeCommand() *cobra.Command {
 
 	// Add command line parameters
 	cmd.Flags().StringVarP(&inputDir, "input", "i", ".", "Specify input directory containing PDF files to merge")
-	cmd.Flags().StringVarP(&outputFile, "output", "o", "merged.pdf", "Specify output filename")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "merged.pdf", "Specify output filename, - writes to stdout")
 	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Display detailed information")
-	cmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "Specify list of PDF files to merge, ignores input parameter if provided") // Added: file list parameter
+	cmd.Flags().StringSliceVarP(&files, "files", "f", []string{}, "Specify list of PDF and image files to merge, - reads a file from stdin, ignores input parameter if provided") // Added: file list parameter
+	cmd.Flags().StringVar(&validation, "validation", "relaxed", "Validation mode used to check every input before merging: strict or relaxed")
+	cmd.Flags().BoolVar(&repair, "repair", false, "Attempt to repair invalid PDF files by rebuilding their cross-reference table")
+	cmd.Flags().BoolVar(&skipInvalid, "skip-invalid", false, "Skip invalid PDF files instead of failing the merge")
+
+	cmd.Flags().StringVar(&order, "order", "", "File order: alphanumeric, natural or none (default alphanumeric for directories, as listed for --files)")
+	cmd.Flags().StringSliceVar(&include, "include", []string{}, "Only merge files whose name matches one of these glob patterns, e.g. 'chapter-*.pdf'")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", []string{}, "Leave out files whose name matches one of these glob patterns")
+	cmd.Flags().BoolVar(&noProgress, "no-progress", false, "Do not show the progress bar (it is only shown when stdout is a terminal and --verbose is off)")
+	cmd.Flags().Bool("force", false, "Overwrite the output file if it already exists, which is the default")
+	cmd.Flags().BoolVar(&noClobber, "no-clobber", false, "Do nothing if the output file already exists, instead of overwriting it")
+	cmd.Flags().StringVar(&ifExists, "if-exists", "overwrite", "What to do when the output file already exists: overwrite, error or skip")
+	cmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Keep running and merge again when input files are added, removed, renamed or modified")
+	cmd.Flags().BoolVar(&appendMode, "append", false, "Add the files to the end of the existing output file with an incremental update, keeping its bookmarks and metadata")
+	cmd.Flags().IntVar(&insertAt, "insert-at", 0, "With --append, insert the files before this page of the existing output file instead of at the end")
+	cmd.MarkFlagsMutuallyExclusive("force", "no-clobber", "if-exists")
+	cmd.MarkFlagsMutuallyExclusive("watch", "append")
 
 	return cmd
 }
 
 func runMerge() error {
-	var result *merger.MergeResult
-	var err error
+	mode, err := merger.ParseValidationMode(validation)
+	if err != nil {
+		return err
+	}
+	sortOrder, err := merger.ParseSortOrder(order)
+	if err != nil {
+		return err
+	}
+
+	// Replace an existing output file unless asked to keep it
+	existing, err := mergecmd.ExistingOutput(ifExists, noClobber)
+	if err != nil {
+		return err
+	}
+
+	// Print messages to stderr when the merged document is written to stdout
+	out := stdio.MessageWriter(outputFile)
+
+	if watchMode && (outputFile == stdio.Name || stdio.HasStdin(files)) {
+		return fmt.Errorf("--watch cannot be used with stdin or stdout (%s)", stdio.Name)
+	}
 
 	// Ensure output file path is absolute
-	if !filepath.IsAbs(outputFile) {
+	if outputFile != stdio.Name && !filepath.IsAbs(outputFile) {
 		absPath, err := filepath.Abs(outputFile)
 		if err != nil {
-			fmt.Printf("Warning: Unable to get absolute path: %v, will use relative path\n", err)
+			fmt.Fprintf(out, "Warning: Unable to get absolute path: %v, will use relative path\n", err)
 		} else {
 			outputFile = absPath
 		}
 	}
 
 	if verbose {
-		fmt.Printf("Output file: %s\n", outputFile)
+		fmt.Fprintf(out, "Output file: %s\n", outputFile)
+	}
+
+	opts := merger.Options{
+		Include:    include,
+		Exclude:    exclude,
+		Order:      sortOrder,
+		OutputFile: outputFile,
+		IfExists:   existing,
+		Format:     merger.FormatPDF,
+		Verbose:    verbose,
+		PDF: merger.PDFOptions{
+			Validation: merger.ValidationOptions{
+				Mode:        mode,
+				Repair:      repair,
+				SkipInvalid: skipInvalid,
+			},
+			Append:   appendMode,
+			InsertAt: insertAt,
+		},
 	}
 
 	// Choose processing mode based on parameters: file list or directory
 	if len(files) > 0 {
 		// Use specified file list
 		if verbose {
-			fmt.Printf("Will merge %d specified files\n", len(files))
+			fmt.Fprintf(out, "Will merge %d specified files\n", len(files))
 		}
-		result, err = merger.MergePDFFiles(files, outputFile, verbose)
+		// Stdin is merged in place of its argument, the other files are checked like any listed file
+		stdin, err := stdio.FileInputs(files)
+		if err != nil {
+			return err
+		}
+		opts.Files = files
+		opts.FileInputs = stdin
 	} else {
 		// Use directory mode
 		// Ensure input directory path exists and is accessible
-		inputInfo, err := os.Stat(inputDir)
-		if err != nil {
+		inputInfo, statErr := os.Stat(inputDir)
+		if statErr != nil {
 			// Try to check if it's a path issue, not file doesn't exist
-			if os.IsNotExist(err) {
-				fmt.Printf("Error: Input directory does not exist: %s\n", inputDir)
-				fmt.Println("Note: If using absolute path, ensure path is completely correct")
+			if os.IsNotExist(statErr) {
+				fmt.Fprintf(out, "Error: Input directory does not exist: %s\n", inputDir)
+				fmt.Fprintln(out, "Note: If using absolute path, ensure path is completely correct")
 			} else {
-				fmt.Printf("Error: Cannot access input directory: %v\n", err)
+				fmt.Fprintf(out, "Error: Cannot access input directory: %v\n", statErr)
 			}
-			return err
+			return statErr
 		}
 
-		if !inputInfo.IsDir() {
-			return fmt.Errorf("%s is not a directory", inputDir)
+		// A ZIP or TAR archive is scanned like a directory
+		if !inputInfo.IsDir() && !merger.IsArchive(inputDir) {
+			return fmt.Errorf("%s is not a directory or archive", inputDir)
+		}
+		if watchMode && !inputInfo.IsDir() {
+			return fmt.Errorf("--watch cannot be used with an archive (%s)", inputDir)
 		}
 
 		if verbose {
-			fmt.Printf("Input directory: %s\n", inputDir)
+			fmt.Fprintf(out, "Input directory: %s\n", inputDir)
 		}
 
-		result, err = merger.MergePDFs(inputDir, outputFile, verbose)
+		opts.InputDir = inputDir
 	}
 
-	if err != nil {
-		return err
+	if outputFile == stdio.Name {
+		opts.OutputFile = ""
+		opts.Output = os.Stdout
+	}
+
+	if verbose {
+		opts.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	report := mergecmd.Reporter{Out: out, Noun: "PDF files"}
+	if appendMode {
+		report.Verb = "appended to"
+	}
+	if watchMode {
+		return report.Watch(opts)
+	}
+
+	// Show a progress bar on interactive terminals, verbose output would break it up
+	var bar *progress.Bar
+	if !noProgress && !verbose && outputFile != stdio.Name && progress.IsTerminal(os.Stdout) {
+		bar = progress.NewBar(os.Stdout, merger.ProgressValidated, merger.ProgressMerged)
+		opts.Progress = bar.Handle
 	}
 
-	fmt.Printf("Success! %d PDF files merged into: %s\n", result.MergedFiles, result.OutputPath)
-	return nil
+	result, err := merger.Merge(opts)
+	if bar != nil {
+		bar.Finish()
+	}
+	return report.PrintResult(result, err)
 }
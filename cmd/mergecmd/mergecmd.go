@@ -0,0 +1,121 @@
+package mergecmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/liliang-cn/pdf-merger/cmd/watch"
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+)
+
+// Reporter prints the outcome of the merges of a merge command
+type Reporter struct {
+	Out  io.Writer // Where messages are printed, stderr when the merged file goes to stdout
+	Noun string    // What the merged files are called in messages, e.g. "PDF files"
+	Verb string    // How the files got into the output in messages, "merged into" when empty
+}
+
+// ExistingOutput returns what happens to an existing output file: it is replaced by default and with --force,
+// kept with --no-clobber, or as selected by --if-exists (overwrite, error or skip)
+func ExistingOutput(ifExists string, noClobber bool) (merger.ExistingOutput, error) {
+	if noClobber {
+		return merger.ExistingSkip, nil
+	}
+	return merger.ParseExistingOutput(ifExists)
+}
+
+// PrintResult prints the outcome of a merge, and returns err with a hint when the output file exists
+func (r Reporter) PrintResult(result *merger.MergeResult, err error) error {
+	if errors.Is(err, merger.ErrOutputExists) {
+		return fmt.Errorf("%w, leave out --if-exists error to overwrite it or use --no-clobber to skip the merge", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if result.OutputSkipped {
+		fmt.Fprintf(r.Out, "Output file already exists, nothing merged: %s\n", result.OutputPath)
+		return nil
+	}
+
+	destination := result.OutputPath
+	if destination == "" {
+		destination = "stdout"
+	}
+	verb := r.Verb
+	if verb == "" {
+		verb = "merged into"
+	}
+	fmt.Fprintf(r.Out, "Success! %d %s %s: %s\n", result.MergedFiles, r.Noun, verb, destination)
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(r.Out, "Warning: %s: %s\n", warning.Path, warning.Message)
+	}
+	return nil
+}
+
+// Watch merges, then merges again each time an input file changes, until interrupted. The input directory
+// is watched for files of the type the format merges, or the directory of the summary file when there is one.
+func (r Reporter) Watch(opts merger.Options) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := merger.MergeContext(ctx, opts)
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case errors.Is(err, merger.ErrInvalidFile), errors.Is(err, merger.ErrNoValidFiles),
+		errors.Is(err, merger.ErrNoInputFiles), errors.Is(err, merger.ErrMergeFailed):
+		// Input problems can be fixed while watching
+		fmt.Fprintf(r.Out, "Merge failed: %v\n", err)
+	case err != nil || result.OutputSkipped:
+		return r.PrintResult(result, err)
+	default:
+		r.PrintResult(result, err)
+		// The output file is now ours to replace
+		opts.IfExists = merger.ExistingOverwrite
+	}
+
+	// The chapters of a summary are usually next to it, the directory of the summary is watched
+	dir := opts.InputDir
+	if opts.Summary != "" {
+		dir = filepath.Dir(opts.Summary)
+	}
+	target := dir
+	if target == "" {
+		target = fmt.Sprintf("%d files", len(opts.Files))
+	}
+	fmt.Fprintf(r.Out, "Watching %s for changes, press Ctrl+C to stop\n", target)
+
+	return watch.Run(ctx, watch.Options{
+		Dir:   dir,
+		Files: opts.Files,
+		Match: func(path string) bool {
+			return merger.FileTypeForPath(path) == opts.Format.InputType()
+		},
+		Ignore: opts.OutputFile,
+	}, func(changed []string) {
+		start := time.Now()
+		result, err := merger.MergeContext(ctx, opts)
+		if ctx.Err() != nil {
+			return
+		}
+		timestamp := start.Format("15:04:05")
+		if err != nil {
+			fmt.Fprintf(r.Out, "[%s] Rebuild failed (changed: %s): %v\n", timestamp, watch.Summary(changed), err)
+			return
+		}
+		opts.IfExists = merger.ExistingOverwrite
+		fmt.Fprintf(r.Out, "[%s] Rebuilt %s: %d %s in %s (changed: %s)\n",
+			timestamp, result.OutputPath, result.MergedFiles, r.Noun, time.Since(start).Round(time.Millisecond), watch.Summary(changed))
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(r.Out, "  Warning: %s: %s\n", warning.Path, warning.Message)
+		}
+	})
+}
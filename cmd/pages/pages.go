@@ -0,0 +1,91 @@
+package pages
+
+import (
+	"fmt"
+
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	outputFile  string
+	after       int
+	sourcePages string
+)
+
+// NewPagesCommand creates a pages subcommand with insert, remove and move subcommands
+func NewPagesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pages",
+		Short: "Insert, remove and reorder pages of a PDF file",
+		Long: `Edit the pages of a single PDF file. Pages are selected with page range expressions: a comma-separated list of pages and ranges such as "1-3,5,8-", where "-3" means up to page 3, "8-" means from page 8 to the end and "last" is the last page.
+
+The file is edited in place unless --output is given, it is only replaced once the edited document has been fully written.`,
+	}
+
+	removeCmd := &cobra.Command{
+		Use:     "remove <file> <pages>",
+		Short:   "Remove pages",
+		Long:    `Remove the selected pages. Bookmarks and links to removed pages point to the next remaining page`,
+		Example: `  pdf-merger pages remove book.pdf 2,10-12`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := merger.RemovePages(args[0], output(args[0]), args[1])
+			return printResult(result, err, "Removed pages")
+		},
+	}
+
+	moveCmd := &cobra.Command{
+		Use:   "move <file> <pages>",
+		Short: "Move pages",
+		Long:  `Move the selected pages after the page given by --after, or to the beginning with --after 0. The moved pages are placed in the order they are listed`,
+		Example: `  pdf-merger pages move book.pdf 5 --after 1
+  pdf-merger pages move book.pdf last,1 --after 0`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := merger.MovePages(args[0], output(args[0]), args[1], after)
+			return printResult(result, err, "Moved pages")
+		},
+	}
+	moveCmd.Flags().IntVar(&after, "after", 0, "Page after which the pages are placed, 0 for the beginning")
+	moveCmd.MarkFlagRequired("after")
+
+	insertCmd := &cobra.Command{
+		Use:   "insert <file> <source>",
+		Short: "Insert pages of another PDF file",
+		Long:  `Insert the pages of the source PDF file after the page given by --after, or at the beginning with --after 0. --pages selects the pages of the source file to insert. If the file has bookmarks, one is added for the inserted pages`,
+		Example: `  pdf-merger pages insert book.pdf appendix.pdf --after 12
+  pdf-merger pages insert book.pdf scans.pdf --pages 2-4 --after 0 -o book-with-scans.pdf`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := merger.InsertPages(args[0], output(args[0]), args[1], sourcePages, after)
+			return printResult(result, err, "Inserted pages")
+		},
+	}
+	insertCmd.Flags().IntVar(&after, "after", 0, "Page after which the pages are inserted, 0 for the beginning")
+	insertCmd.Flags().StringVar(&sourcePages, "pages", "", "Pages of the source file to insert, all pages by default")
+	insertCmd.MarkFlagRequired("after")
+
+	// Add command line parameters
+	cmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "", "Write the edited document to this file instead of editing the file in place")
+
+	cmd.AddCommand(insertCmd, removeCmd, moveCmd)
+	return cmd
+}
+
+// output returns the file the edited document is written to
+func output(file string) string {
+	if outputFile != "" {
+		return outputFile
+	}
+	return file
+}
+
+func printResult(result *merger.PagesResult, err error, action string) error {
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Success! %s %s, %s now has %d pages\n", action, merger.FormatPageRanges(result.Pages), result.OutputPath, result.PageCount)
+	return nil
+}
@@ -0,0 +1,163 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+)
+
+const (
+	barWidth       = 30
+	redrawInterval = 100 * time.Millisecond
+)
+
+// IsTerminal reports whether f is an interactive terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Bar renders merge progress events as a single-line progress bar with ETA
+type Bar struct {
+	out        io.Writer
+	stages     []merger.ProgressStage // Stages that make up the whole merge, each weighted equally
+	start      time.Time
+	lastDraw   time.Time
+	lastStage  merger.ProgressStage
+	lastWidth  int
+	scanned    int
+	fraction   float64
+	pages      int
+	written    int64
+	statusText string
+}
+
+// NewBar creates a progress bar writing to out. stages lists the stages with a known
+// number of files that the merge goes through, in order, and is used to compute the ETA.
+func NewBar(out io.Writer, stages ...merger.ProgressStage) *Bar {
+	return &Bar{
+		out:    out,
+		stages: stages,
+		start:  time.Now(),
+	}
+}
+
+// Handle updates the bar with a progress event, it can be used as merger.Options.Progress
+func (b *Bar) Handle(event merger.ProgressEvent) {
+	switch event.Stage {
+	case merger.ProgressScanned:
+		b.scanned = event.Current
+		b.statusText = fmt.Sprintf("Scanning, %d files found", b.scanned)
+	case merger.ProgressWritten:
+		b.written = event.BytesWritten
+		b.statusText = fmt.Sprintf("Writing %s", FormatSize(b.written))
+	default:
+		if event.Pages > 0 {
+			b.pages = event.Pages
+		}
+		b.fraction = b.stageFraction(event)
+		b.statusText = fmt.Sprintf("%s %d/%d", stageLabel(event.Stage), event.Current, event.Total)
+		if b.pages > 0 {
+			b.statusText += fmt.Sprintf(", %d pages", b.pages)
+		}
+	}
+
+	// Redraw at most every redrawInterval, but always when the stage changes
+	now := time.Now()
+	if event.Stage == b.lastStage && now.Sub(b.lastDraw) < redrawInterval {
+		return
+	}
+	b.lastStage = event.Stage
+	b.lastDraw = now
+	b.draw(now)
+}
+
+// Finish clears the bar so that following output starts on an empty line
+func (b *Bar) Finish() {
+	if b.lastWidth > 0 {
+		fmt.Fprintf(b.out, "\r%s\r", strings.Repeat(" ", b.lastWidth))
+		b.lastWidth = 0
+	}
+}
+
+// stageFraction returns the overall progress after event, between 0 and 1
+func (b *Bar) stageFraction(event merger.ProgressEvent) float64 {
+	if event.Total == 0 || len(b.stages) == 0 {
+		return b.fraction
+	}
+	for i, stage := range b.stages {
+		if stage == event.Stage {
+			done := float64(i) + float64(event.Current)/float64(event.Total)
+			return done / float64(len(b.stages))
+		}
+	}
+	return b.fraction
+}
+
+// draw renders the bar on a single line
+func (b *Bar) draw(now time.Time) {
+	filled := int(b.fraction * barWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+
+	line := fmt.Sprintf("[%s] %3.0f%%  %s", bar, b.fraction*100, b.statusText)
+	if b.fraction > 0 && b.fraction < 1 {
+		elapsed := now.Sub(b.start)
+		eta := time.Duration(float64(elapsed) * (1 - b.fraction) / b.fraction)
+		line += "  ETA " + formatDuration(eta)
+	}
+
+	// Pad with spaces to overwrite the rest of a longer previous line
+	width := len(line)
+	if width < b.lastWidth {
+		line += strings.Repeat(" ", b.lastWidth-width)
+	}
+	b.lastWidth = width
+	fmt.Fprintf(b.out, "\r%s", line)
+}
+
+// stageLabel returns the text shown for a stage
+func stageLabel(stage merger.ProgressStage) string {
+	switch stage {
+	case merger.ProgressValidated:
+		return "Validating"
+	case merger.ProgressMerged:
+		return "Merging"
+	}
+	return string(stage)
+}
+
+// formatDuration formats d as m:ss, or h:mm:ss for durations of an hour or more
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// FormatSize formats a byte count using binary units, e.g. 1.5 MiB
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
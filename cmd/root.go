@@ -3,8 +3,13 @@ package cmd
 import (
 	"os"
 
+	"github.com/liliang-cn/pdf-merger/cmd/export"
+	"github.com/liliang-cn/pdf-merger/cmd/extract"
+	"github.com/liliang-cn/pdf-merger/cmd/info"
 	"github.com/liliang-cn/pdf-merger/cmd/merge"
 	mergemd "github.com/liliang-cn/pdf-merger/cmd/merge-md"
+	mergetext "github.com/liliang-cn/pdf-merger/cmd/merge-text"
+	"github.com/liliang-cn/pdf-merger/cmd/pages"
 	"github.com/liliang-cn/pdf-merger/cmd/serve"
 
 	"github.com/spf13/cobra"
@@ -29,5 +34,10 @@ func init() {
 	// Add subcommands
 	rootCmd.AddCommand(merge.NewMergeCommand())
 	rootCmd.AddCommand(mergemd.NewMergeMdCommand())
+	rootCmd.AddCommand(mergetext.NewMergeTextCommand())
 	rootCmd.AddCommand(serve.NewServeCommand())
+	rootCmd.AddCommand(info.NewInfoCommand())
+	rootCmd.AddCommand(pages.NewPagesCommand())
+	rootCmd.AddCommand(extract.NewExtractCommand())
+	rootCmd.AddCommand(export.NewExportCommand())
 }
@@ -1,12 +1,17 @@
 package serve
 
 import (
+	"time"
+
 	"github.com/liliang-cn/pdf-merger/api"
 
 	"github.com/spf13/cobra"
 )
 
-var port int
+var (
+	port             int
+	maxMergeDuration time.Duration
+)
 
 // NewServeCommand creates a serve subcommand
 func NewServeCommand() *cobra.Command {
@@ -21,10 +26,14 @@ func NewServeCommand() *cobra.Command {
 
 	// Add command line parameters
 	cmd.Flags().IntVarP(&port, "port", "p", 6759, "API server listening port")
+	cmd.Flags().DurationVar(&maxMergeDuration, "max-merge-duration", api.DefaultMaxMergeDuration, "Cancel merges running longer than this duration, 0 disables the limit")
 
 	return cmd
 }
 
 func runServe() error {
-	return api.StartServer(port)
+	return api.StartServerWithConfig(api.Config{
+		Port:             port,
+		MaxMergeDuration: maxMergeDuration,
+	})
 }
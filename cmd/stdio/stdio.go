@@ -0,0 +1,67 @@
+package stdio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/liliang-cn/pdf-merger/pkg/merger"
+)
+
+// Name is the file argument that selects stdin as an input or stdout as the output
+const Name = "-"
+
+// stdinName is the name of the stdin input in messages, bookmarks and Markdown titles
+const stdinName = "stdin"
+
+// HasStdin reports whether files contains the stdin argument
+func HasStdin(files []string) bool {
+	return slices.Contains(files, Name)
+}
+
+// Inputs converts file arguments to merge inputs in the given order, reading the document for the
+// stdin argument from stdin. Stdin can only be used once.
+func Inputs(files []string) ([]merger.Input, error) {
+	stdin, err := FileInputs(files)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([]merger.Input, 0, len(files))
+	for _, file := range files {
+		if in, ok := stdin[file]; ok {
+			inputs = append(inputs, in)
+		} else {
+			inputs = append(inputs, merger.Input{Path: file})
+		}
+	}
+	return inputs, nil
+}
+
+// FileInputs reads the document for the stdin argument in files from stdin, for merger.Options.FileInputs.
+// It returns nil when files do not contain the stdin argument, which can only be used once.
+func FileInputs(files []string) (map[string]merger.Input, error) {
+	switch i := slices.Index(files, Name); {
+	case i < 0:
+		return nil, nil
+	case slices.Contains(files[i+1:], Name):
+		return nil, fmt.Errorf("Stdin (%s) can only be used once as an input", Name)
+	}
+
+	// Stdin is usually a pipe, which cannot seek, so the document is kept in memory
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read stdin: %w", err)
+	}
+	return map[string]merger.Input{Name: {Name: stdinName, Reader: bytes.NewReader(content)}}, nil
+}
+
+// MessageWriter returns where command messages are printed: stderr when the merged document
+// is written to stdout, stdout otherwise
+func MessageWriter(outputFile string) io.Writer {
+	if outputFile == Name {
+		return os.Stderr
+	}
+	return os.Stdout
+}
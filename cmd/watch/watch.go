@@ -0,0 +1,166 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultDebounce is how long changes must stop before a rebuild, so that saving or copying
+// several files triggers a single rebuild
+const DefaultDebounce = 300 * time.Millisecond
+
+// Options selects the files whose changes trigger a rebuild
+type Options struct {
+	Dir      string                 // Directory watched recursively, for files accepted by Match
+	Files    []string               // Files watched individually
+	Match    func(path string) bool // Reports whether a file found in Dir is an input, e.g. by extension
+	Ignore   string                 // Output file, its changes never trigger a rebuild
+	Debounce time.Duration          // DefaultDebounce when zero
+}
+
+// watcher tracks the watched directories and files for Run
+type watcher struct {
+	fs     *fsnotify.Watcher
+	opts   Options
+	dirs   map[string]bool // Directories watched for Dir, added as they are created
+	files  map[string]bool // Absolute paths of Files
+	ignore string
+}
+
+// Run watches the files selected by opts and calls rebuild with the changed paths, sorted, after each
+// batch of changes. rebuild runs on the calling goroutine, changes made meanwhile are collected for the
+// next batch. Run returns nil when ctx is done.
+func Run(ctx context.Context, opts Options, rebuild func(changed []string)) error {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Failed to start watching: %w", err)
+	}
+	defer fs.Close()
+
+	w := &watcher{fs: fs, opts: opts, dirs: map[string]bool{}, files: map[string]bool{}}
+	if opts.Ignore != "" {
+		w.ignore = absPath(opts.Ignore)
+	}
+	if opts.Dir != "" {
+		if err := w.addTree(absPath(opts.Dir)); err != nil {
+			return err
+		}
+	}
+	for _, file := range opts.Files {
+		file = absPath(file)
+		w.files[file] = true
+		// Editors often replace files on save, so the parent directory is watched rather than the file
+		if err := fs.Add(filepath.Dir(file)); err != nil {
+			return fmt.Errorf("Failed to watch %s: %w", filepath.Dir(file), err)
+		}
+	}
+
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	changed := map[string]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-fs.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("Watch failed: %w", err)
+		case event, ok := <-fs.Events:
+			if !ok {
+				return nil
+			}
+			if w.relevant(event) {
+				changed[event.Name] = true
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			slices.Sort(paths)
+			clear(changed)
+			rebuild(paths)
+		}
+	}
+}
+
+// relevant reports whether event changes an input, and starts watching directories created in Dir
+func (w *watcher) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod || event.Name == w.ignore {
+		return false
+	}
+	if w.files[event.Name] {
+		return true
+	}
+	if w.opts.Dir == "" {
+		return false
+	}
+
+	// A directory removed or renamed takes its inputs with it
+	if w.dirs[event.Name] && (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) {
+		delete(w.dirs, event.Name)
+		return true
+	}
+	// A new directory may have been moved in with inputs
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			w.addTree(event.Name)
+			return true
+		}
+	}
+	return w.opts.Match == nil || w.opts.Match(event.Name)
+}
+
+// addTree watches dir and its subdirectories
+func (w *watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || w.dirs[path] {
+			return nil
+		}
+		if err := w.fs.Add(path); err != nil {
+			return fmt.Errorf("Failed to watch %s: %w", path, err)
+		}
+		w.dirs[path] = true
+		return nil
+	})
+}
+
+// absPath returns the absolute form of path, or path itself if it cannot be determined
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// Summary lists the names of changed files for a rebuild message, at most three followed by a count
+func Summary(changed []string) string {
+	const shown = 3
+	names := make([]string, 0, shown)
+	for i, path := range changed {
+		if i == shown {
+			names = append(names, fmt.Sprintf("+%d more", len(changed)-shown))
+			break
+		}
+		names = append(names, filepath.Base(path))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,349 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// appendPDFs adds the PDF inputs to the base document and writes the result to w as an incremental update:
+// the unchanged bytes of base followed by the new and modified objects. Bookmarks, metadata and every
+// existing object of base are kept. The inputs are inserted before page insertAt, or after the last page when it is 0.
+func appendPDFs(ctx context.Context, base Input, inputs []Input, insertAt int, w io.Writer, progress ProgressFunc) error {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.MERGEAPPEND
+	conf.ValidationMode = model.ValidationRelaxed
+
+	content, err := base.readAll()
+	if err != nil {
+		return err
+	}
+	dest, err := readPDF(ctx, bytes.NewReader(content), conf)
+	if err != nil {
+		return fmt.Errorf("Cannot read %s: %w", base.displayName(), err)
+	}
+	if dest.XRefTable.Version() < model.V14 {
+		return fmt.Errorf("Cannot append to %s: incremental updates need PDF 1.4 or later, merge without append to rewrite it", base.displayName())
+	}
+	if insertAt < 0 || insertAt > dest.PageCount {
+		return newError(ErrInvalidOptions, nil, "Cannot insert before page %d, %s has %d pages", insertAt, base.displayName(), dest.PageCount)
+	}
+
+	oldSize := *dest.XRefTable.Size
+	snapshot := snapshotObjects(dest)
+
+	if conf.CreateBookmarks {
+		if err := ensureAppendOutlines(dest, filepath.Base(base.displayName())); err != nil {
+			return err
+		}
+	}
+
+	for i, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		src, err := readPDFInput(ctx, in, conf)
+		if err != nil {
+			return err
+		}
+		if dest.XRefTable.Version() < model.V20 && src.XRefTable.Version() == model.V20 {
+			return pdfcpu.ErrUnsupportedVersion
+		}
+		// The header cannot be changed by an incremental update, the catalog version takes precedence over it
+		if version := src.XRefTable.Version(); version > dest.XRefTable.Version() {
+			catalog, err := dest.Catalog()
+			if err != nil {
+				return err
+			}
+			catalog["Version"] = types.Name(version.String())
+			dest.RootVersion = &version
+		}
+
+		if err := pdfcpu.MergeXRefTables(filepath.Base(in.displayName()), src, dest, false, false); err != nil {
+			return err
+		}
+		if err := relinkAppendedPages(dest, insertAt); err != nil {
+			return err
+		}
+		if insertAt > 0 && conf.CreateBookmarks {
+			if err := moveLastBookmark(dest, insertAt); err != nil {
+				return err
+			}
+		}
+		// Inputs keep their order, the next one goes after the pages of this one
+		if insertAt > 0 {
+			insertAt += src.PageCount
+		}
+		progress.report(ProgressEvent{Stage: ProgressMerged, File: in.displayName(), Current: i + 1, Total: len(inputs), Pages: dest.PageCount})
+	}
+
+	// Name trees, e.g. named destinations, are kept in memory while merging and written back to their objects here
+	if err := dest.BindNameTrees(); err != nil {
+		return err
+	}
+
+	// Write the original document unchanged, followed by the increment
+	out := &contextWriter{ctx: ctx, w: &progressWriter{w: w, progress: progress}}
+	if _, err := out.Write(content); err != nil {
+		return err
+	}
+
+	// Use the cross-reference format of the original document, older readers do not support mixing them
+	dest.WriteXRefStream = dest.Read.UsingXRefStreams
+	dest.Write.Increment = true
+	dest.Write.Offset = int64(len(content))
+	dest.Write.ObjNrs = changedObjects(dest, snapshot, oldSize)
+	return api.WriteIncrement(dest, out)
+}
+
+// snapshotObjects returns the serialized form of every object of ctx, used to find the objects changed by the merge
+func snapshotObjects(ctx *model.Context) map[int]string {
+	snapshot := make(map[int]string, len(ctx.Table))
+	for objNr, entry := range ctx.Table {
+		if entry.Free || entry.Object == nil {
+			continue
+		}
+		snapshot[objNr] = objectString(entry.Object)
+	}
+	return snapshot
+}
+
+// changedObjects returns the numbers of the objects of ctx that were added or modified since snapshot was taken
+func changedObjects(ctx *model.Context, snapshot map[int]string, oldSize int) []int {
+	var objNrs []int
+	for objNr, entry := range ctx.Table {
+		if objNr == 0 || entry.Free || entry.Object == nil {
+			continue
+		}
+		// Object and xref streams of the inputs were unpacked when reading, their objects are written individually
+		switch entry.Object.(type) {
+		case types.ObjectStreamDict, types.XRefStreamDict:
+			continue
+		}
+		if objNr >= oldSize || snapshot[objNr] != objectString(entry.Object) {
+			objNrs = append(objNrs, objNr)
+		}
+	}
+	slices.Sort(objNrs)
+	return objNrs
+}
+
+// objectString returns a representation of o that changes when o is modified
+func objectString(o types.Object) string {
+	if sd, ok := o.(types.StreamDict); ok {
+		return fmt.Sprintf("%s stream %d", sd.Dict.PDFString(), len(sd.Raw))
+	}
+	return o.PDFString()
+}
+
+// ensureAppendOutlines makes sure the document has an outline that bookmarks for the appended files can be added to,
+// keeping existing bookmarks as they are. A document without bookmarks gets one for its first page.
+func ensureAppendOutlines(ctx *model.Context, name string) error {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	if obj, ok := catalog.Find("Outlines"); ok {
+		outlines, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return err
+		}
+		if outlines.IndirectRefEntry("Last") != nil {
+			return nil
+		}
+		// An empty outline is replaced
+		catalog.Delete("Outlines")
+	}
+	return pdfcpu.EnsureOutlines(ctx, name, false)
+}
+
+// moveLastBookmark moves the last top-level bookmark, added for pages inserted at page, in front of the first
+// top-level bookmark pointing to a later page, so that bookmarks stay in page order
+func moveLastBookmark(ctx *model.Context, page int) error {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	outlines, err := ctx.DereferenceDict(catalog["Outlines"])
+	if err != nil {
+		return err
+	}
+	lastRef := outlines.IndirectRefEntry("Last")
+	if lastRef == nil {
+		return nil
+	}
+
+	// Find the first bookmark after the inserted pages
+	var targetRef *types.IndirectRef
+	var target types.Dict
+	for ref := outlines.IndirectRefEntry("First"); ref != nil && ref.ObjectNumber != lastRef.ObjectNumber; {
+		item, err := ctx.DereferenceDict(*ref)
+		if err != nil {
+			return err
+		}
+		if itemPage, ok := bookmarkPage(ctx, item); ok && itemPage > page {
+			targetRef, target = ref, item
+			break
+		}
+		ref = item.IndirectRefEntry("Next")
+	}
+	if targetRef == nil {
+		return nil
+	}
+
+	// Unlink the last bookmark
+	last, err := ctx.DereferenceDict(*lastRef)
+	if err != nil {
+		return err
+	}
+	prevRef := last.IndirectRefEntry("Previous")
+	if prevRef == nil {
+		return nil
+	}
+	prev, err := ctx.DereferenceDict(*prevRef)
+	if err != nil {
+		return err
+	}
+	prev.Delete("Next")
+	outlines["Last"] = *prevRef
+	last.Delete("Previous")
+
+	// Link it in front of the target
+	if beforeRef := target.IndirectRefEntry("Previous"); beforeRef != nil {
+		before, err := ctx.DereferenceDict(*beforeRef)
+		if err != nil {
+			return err
+		}
+		before["Next"] = *lastRef
+		last["Previous"] = *beforeRef
+	} else {
+		outlines["First"] = *lastRef
+	}
+	last["Next"] = *targetRef
+	target["Previous"] = *lastRef
+	return nil
+}
+
+// bookmarkPage returns the page an outline item points to, through its destination or a GoTo action
+func bookmarkPage(ctx *model.Context, item types.Dict) (int, bool) {
+	dest, ok := item.Find("Dest")
+	if !ok {
+		action, err := ctx.DereferenceDict(item["A"])
+		if err != nil || action == nil {
+			return 0, false
+		}
+		if dest, ok = action.Find("D"); !ok {
+			return 0, false
+		}
+	}
+	page, err := pdfcpu.PageNrFromDestination(ctx, dest)
+	return page, err == nil && page > 0
+}
+
+// relinkAppendedPages moves the pages added by the last call to pdfcpu.MergeXRefTables into the previous page tree,
+// in front of page before, or after the last page when before is 0. MergeXRefTables puts the previous page tree and
+// the new pages under a new root node, relinking them avoids growing the page tree by one level per append.
+func relinkAppendedPages(ctx *model.Context, before int) error {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return err
+	}
+	wrapperRef, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	wrapper, err := ctx.DereferenceDict(*wrapperRef)
+	if err != nil {
+		return err
+	}
+	kids := wrapper.ArrayEntry("Kids")
+	if len(kids) != 2 {
+		return fmt.Errorf("Unexpected page tree after merging: %d root nodes", len(kids))
+	}
+	rootRef, ok1 := kids[0].(types.IndirectRef)
+	newRef, ok2 := kids[1].(types.IndirectRef)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("Unexpected page tree after merging")
+	}
+
+	root, err := ctx.DereferenceDict(rootRef)
+	if err != nil {
+		return err
+	}
+	newPages, err := ctx.DereferenceDict(newRef)
+	if err != nil {
+		return err
+	}
+	count := newPages.IntEntry("Count")
+	if count == nil {
+		return fmt.Errorf("Page tree node %d has no page count", newRef.ObjectNumber)
+	}
+
+	// Restore the previous root
+	catalog["Pages"] = rootRef
+	root.Delete("Parent")
+	if err := ctx.FreeObject(wrapperRef.ObjectNumber.Value()); err != nil {
+		return err
+	}
+
+	// Find the node the new pages are added to, and their position among its kids
+	parentRef := rootRef
+	position := len(root.ArrayEntry("Kids"))
+	if before > 0 {
+		pageRef, err := ctx.PageDictIndRef(before)
+		if err != nil {
+			return err
+		}
+		page, err := ctx.DereferenceDict(*pageRef)
+		if err != nil {
+			return err
+		}
+		ref := page.IndirectRefEntry("Parent")
+		if ref == nil {
+			return fmt.Errorf("Page %d has no parent", before)
+		}
+		parentRef = *ref
+		parent, err := ctx.DereferenceDict(parentRef)
+		if err != nil {
+			return err
+		}
+		position = slices.IndexFunc(parent.ArrayEntry("Kids"), func(o types.Object) bool {
+			kid, ok := o.(types.IndirectRef)
+			return ok && kid.ObjectNumber == pageRef.ObjectNumber
+		})
+		if position < 0 {
+			return fmt.Errorf("Page %d is not listed by its parent", before)
+		}
+	}
+
+	parent, err := ctx.DereferenceDict(parentRef)
+	if err != nil {
+		return err
+	}
+	parent["Kids"] = slices.Insert(slices.Clone(parent.ArrayEntry("Kids")), position, types.Object(newRef))
+	newPages["Parent"] = parentRef
+
+	// Update the page counts from the parent up to the root
+	for ref := &parentRef; ref != nil; {
+		node, err := ctx.DereferenceDict(*ref)
+		if err != nil {
+			return err
+		}
+		nodeCount := node.IntEntry("Count")
+		if nodeCount == nil {
+			return fmt.Errorf("Page tree node %d has no page count", ref.ObjectNumber)
+		}
+		node["Count"] = types.Integer(*nodeCount + *count)
+		ref = node.IndirectRefEntry("Parent")
+	}
+	return nil
+}
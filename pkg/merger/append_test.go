@@ -0,0 +1,126 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// copyTestdata copies a file of testdata into a temporary directory and returns its path
+func copyTestdata(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAppendPDF(t *testing.T) {
+	// valid.pdf has a 200x200 page, small.pdf a 100x100 page
+	tests := []struct {
+		insertAt int
+		inputs   int
+		want     []float64 // Page widths of the output
+	}{
+		{0, 1, []float64{200, 100}},
+		{1, 1, []float64{100, 200}},
+		{0, 2, []float64{200, 100, 100}},
+		{1, 2, []float64{100, 100, 200}},
+	}
+	for _, tt := range tests {
+		output := copyTestdata(t, "valid.pdf")
+		original, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for range tt.inputs {
+			files = append(files, filepath.Join("testdata", "small.pdf"))
+		}
+
+		_, err = MergeContext(context.Background(), Options{
+			Files:      files,
+			OutputFile: output,
+			Format:     FormatPDF,
+			PDF:        PDFOptions{Append: true, InsertAt: tt.insertAt},
+		})
+		if err != nil {
+			t.Errorf("append %d files at %d error = %v", tt.inputs, tt.insertAt, err)
+			continue
+		}
+
+		appended, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(appended, original) {
+			t.Errorf("append %d files at %d rewrote the original content", tt.inputs, tt.insertAt)
+		}
+		dims, err := api.PageDims(bytes.NewReader(appended), nil)
+		if err != nil {
+			t.Errorf("append %d files at %d wrote an unreadable PDF: %v", tt.inputs, tt.insertAt, err)
+			continue
+		}
+		var widths []float64
+		for _, dim := range dims {
+			widths = append(widths, dim.Width)
+		}
+		if len(widths) != len(tt.want) {
+			t.Errorf("append %d files at %d page widths = %v, want %v", tt.inputs, tt.insertAt, widths, tt.want)
+			continue
+		}
+		for i := range widths {
+			if widths[i] != tt.want[i] {
+				t.Errorf("append %d files at %d page widths = %v, want %v", tt.inputs, tt.insertAt, widths, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAppendPDFErrors(t *testing.T) {
+	tests := []struct {
+		desc     string
+		header   string
+		insertAt int
+		want     string
+	}{
+		{"insert past the last page", "%PDF-1.4", 2, "Cannot insert before page 2"},
+		{"negative page", "%PDF-1.4", -1, "Invalid page -1"},
+		{"PDF 1.3 output", "%PDF-1.3", 0, "incremental updates need PDF 1.4"},
+	}
+	for _, tt := range tests {
+		output := copyTestdata(t, "valid.pdf")
+		content, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = append([]byte(tt.header), content[len("%PDF-1.4"):]...)
+		if err := os.WriteFile(output, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = MergeContext(context.Background(), Options{
+			Files:      []string{filepath.Join("testdata", "small.pdf")},
+			OutputFile: output,
+			Format:     FormatPDF,
+			PDF:        PDFOptions{Append: true, InsertAt: tt.insertAt},
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("append with %s error = %v, want %q", tt.desc, err, tt.want)
+		}
+		if after, _ := os.ReadFile(output); !bytes.Equal(after, content) {
+			t.Errorf("append with %s changed the output file", tt.desc)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package merger
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveFileType is the type of ZIP and TAR archives, whose files are merged as if they were in a directory
+const archiveFileType = "archive"
+
+// ArchiveOptions limits what is read from ZIP and TAR archives given as inputs, to guard against
+// decompression bombs. The files of an archive are read into memory.
+type ArchiveOptions struct {
+	MaxSize  int64 // Total size of the files read from one archive, 512 MiB when 0
+	MaxFiles int   // Number of files in one archive, 10000 when 0
+}
+
+// limits returns the limits of o with the defaults applied
+func (o ArchiveOptions) limits() (int64, int) {
+	maxSize, maxFiles := o.MaxSize, o.MaxFiles
+	if maxSize <= 0 {
+		maxSize = 512 << 20
+	}
+	if maxFiles <= 0 {
+		maxFiles = 10000
+	}
+	return maxSize, maxFiles
+}
+
+// archiveEntry is a regular file read from an archive
+type archiveEntry struct {
+	name string // Slash-separated path inside the archive
+	data []byte
+}
+
+// archivedFile locates an input read from an archive, along with the other files read from the archive
+type archivedFile struct {
+	archive string            // Path of the archive
+	name    string            // Slash-separated path inside the archive
+	files   map[string][]byte // Files read from the archive by path, for the includes of Markdown files
+}
+
+// readArchive returns the regular files of the ZIP, TAR or gzip-compressed TAR archive at file whose cleaned
+// name keep accepts. Entries with absolute paths or paths leaving the archive (zip-slip) make the archive invalid,
+// as do files exceeding the limits of opts. Directories, links, other special entries and files keep rejects are
+// left out without being read, so they do not count against the limits.
+func readArchive(ctx context.Context, file string, opts ArchiveOptions, keep func(name string) bool) ([]archiveEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	r := &archiveReader{ctx: ctx, file: file, keep: keep}
+	r.maxSize, r.maxFiles = opts.limits()
+	r.remaining = r.maxSize
+	switch {
+	case bytes.HasPrefix(head[:n], []byte("PK\x03\x04")), bytes.HasPrefix(head[:n], []byte("PK\x05\x06")):
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		err = r.readZip(f, info.Size())
+		return r.entries, err
+	case bytes.HasPrefix(head[:n], []byte("\x1f\x8b")):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, &InvalidFileError{Path: file, Reason: "cannot decompress: " + err.Error()}
+		}
+		defer gz.Close()
+		err = r.readTar(gz)
+		return r.entries, err
+	}
+	err = r.readTar(f)
+	return r.entries, err
+}
+
+// archiveReader collects the entries of an archive within the size and file count limits
+type archiveReader struct {
+	ctx       context.Context
+	file      string
+	keep      func(name string) bool
+	maxSize   int64
+	remaining int64 // Bytes that may still be read
+	maxFiles  int
+	entries   []archiveEntry
+}
+
+// invalid returns an *InvalidFileError for the archive
+func (r *archiveReader) invalid(format string, args ...any) error {
+	return &InvalidFileError{Path: r.file, Reason: fmt.Sprintf(format, args...)}
+}
+
+// add reads an entry called name from open, checking its path and the limits. Entries r.keep rejects are
+// not opened.
+func (r *archiveReader) add(name string, open func() (io.ReadCloser, error)) error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	clean, ok := safeArchivePath(name)
+	if !ok {
+		return r.invalid("unsafe path %q in archive", name)
+	}
+	if !r.keep(clean) {
+		return nil
+	}
+	if len(r.entries) == r.maxFiles {
+		return r.invalid("more than %d files in archive", r.maxFiles)
+	}
+
+	rc, err := open()
+	if err != nil {
+		return r.invalid("cannot read %s: %v", clean, err)
+	}
+	defer rc.Close()
+
+	// The sizes in entry headers can lie, the bytes actually read are counted
+	data, err := io.ReadAll(io.LimitReader(rc, r.remaining+1))
+	if err != nil {
+		return r.invalid("cannot read %s: %v", clean, err)
+	}
+	if int64(len(data)) > r.remaining {
+		return r.invalid("archive expands to more than the limit of %d bytes", r.maxSize)
+	}
+	r.remaining -= int64(len(data))
+	r.entries = append(r.entries, archiveEntry{name: clean, data: data})
+	return nil
+}
+
+// readZip reads the regular files of a ZIP archive
+func (r *archiveReader) readZip(ra io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return r.invalid("cannot read ZIP archive: %v", err)
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		if err := r.add(f.Name, f.Open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readTar reads the regular files of a TAR archive
+func (r *archiveReader) readTar(rd io.Reader) error {
+	tr := tar.NewReader(rd)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return r.invalid("cannot read TAR archive: %v", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err := r.add(h.Name, open); err != nil {
+			return err
+		}
+	}
+}
+
+// safeArchivePath returns the cleaned slash-separated form of an entry name, and false when it is absolute
+// or leaves the archive through ..
+func safeArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || (len(name) >= 2 && name[1] == ':') {
+		return name, false
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return name, false
+	}
+	return clean, true
+}
+
+// IsArchive reports whether path is a regular file with the content of a ZIP or TAR archive, which can be given
+// as Options.InputDir or in Options.Files
+func IsArchive(path string) bool {
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return false
+	}
+	fileType, _ := DetectFileType(path)
+	return fileType == archiveFileType
+}
+
+// isArchive reports whether head starts with the signature of a ZIP, gzip or TAR archive
+func isArchive(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")) ||
+		bytes.HasPrefix(head, []byte("\x1f\x8b")) ||
+		(len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")))
+}
@@ -0,0 +1,75 @@
+package merger
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestZip writes a ZIP archive holding files to a temporary directory and returns its path
+func writeTestZip(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestReadArchiveSkipsIgnoredEntries(t *testing.T) {
+	file := writeTestZip(t, map[string][]byte{
+		"docs/a.pdf":      []byte("%PDF-1.7 a"),
+		"docs/b.pdf":      []byte("%PDF-1.7 b"),
+		"media/intro.mp4": make([]byte, 4<<20),
+	})
+	isPDF := func(name string) bool { return path.Ext(name) == ".pdf" }
+
+	entries, err := readArchive(context.Background(), file, ArchiveOptions{MaxSize: 1 << 20}, isPDF)
+	if err != nil {
+		t.Fatalf("readArchive() error = %v, the ignored entry counted against the size limit", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("readArchive() returned %d entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if !isPDF(entry.name) {
+			t.Errorf("readArchive() returned ignored entry %s", entry.name)
+		}
+	}
+
+	all := func(string) bool { return true }
+	_, err = readArchive(context.Background(), file, ArchiveOptions{MaxSize: 1 << 20}, all)
+	if !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("readArchive() of all entries error = %v, want the size limit to be exceeded", err)
+	}
+}
+
+func TestReadArchiveRejectsUnsafePaths(t *testing.T) {
+	for _, name := range []string{"../evil.pdf", "docs/../../evil.pdf", "/etc/evil.pdf", "C:/evil.pdf"} {
+		file := writeTestZip(t, map[string][]byte{"ok.pdf": []byte("%PDF-1.7"), name: []byte("%PDF-1.7")})
+		// Unsafe paths are rejected even when the entry would be left out
+		none := func(string) bool { return false }
+		if _, err := readArchive(context.Background(), file, ArchiveOptions{}, none); !errors.Is(err, ErrInvalidFile) {
+			t.Errorf("readArchive() with entry %q error = %v, want an invalid file", name, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package merger
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// LineEnding is the line break style written to merged Markdown files
+type LineEnding string
+
+const (
+	// LineEndingLF ends lines with \n
+	LineEndingLF LineEnding = "lf"
+	// LineEndingCRLF ends lines with \r\n
+	LineEndingCRLF LineEnding = "crlf"
+)
+
+// ParseLineEnding converts a string to a LineEnding, an empty string keeps the line endings of the inputs
+func ParseLineEnding(s string) (LineEnding, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return "", nil
+	case "lf", "unix":
+		return LineEndingLF, nil
+	case "crlf", "windows":
+		return LineEndingCRLF, nil
+	}
+	return "", newError(ErrInvalidOptions, nil, "Unknown line ending %q, must be lf or crlf", s)
+}
+
+// CheckEncoding reports an error if name is neither empty, "auto" nor a known text encoding
+func CheckEncoding(name string) error {
+	_, err := textEncoding(name)
+	return err
+}
+
+// textEncoding returns the encoding called name, nil when the encoding is to be detected
+func textEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return nil, nil
+	case "utf-16", "utf16":
+		// Without a byte order mark, UTF-16 is little endian as written by Windows
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, newError(ErrInvalidOptions, err, "Unknown encoding %q, e.g. utf-8, utf-16le, gbk or windows-1252", name)
+	}
+	return enc, nil
+}
+
+// decodeText converts content in the named encoding to UTF-8 without byte order mark. When name is empty
+// or "auto", the encoding is detected: a byte order mark, NUL bytes of UTF-16 text, valid UTF-8, valid
+// GB18030 (a superset of GBK), and Windows-1252 otherwise. It returns the name of the encoding used.
+func decodeText(content []byte, name string) ([]byte, string, error) {
+	enc, err := textEncoding(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if enc == nil {
+		enc, name = detectEncoding(content)
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if enc == unicode.UTF8 {
+		return bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")), name, nil
+	}
+
+	out, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return nil, name, err
+	}
+	return bytes.TrimPrefix(out, []byte("\xef\xbb\xbf")), name, nil
+}
+
+// detectEncoding guesses the encoding of content and returns it with its name
+func detectEncoding(content []byte) (encoding.Encoding, string) {
+	switch {
+	case bytes.HasPrefix(content, []byte("\xef\xbb\xbf")):
+		return unicode.UTF8, "utf-8"
+	case bytes.HasPrefix(content, []byte("\xff\xfe")):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le"
+	case bytes.HasPrefix(content, []byte("\xfe\xff")):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be"
+	}
+
+	// Text in UTF-16 has a NUL byte in most ASCII characters, on the odd positions for little endian
+	if len(content) >= 2 && len(content)%2 == 0 {
+		var even, odd int
+		for i := 0; i+1 < len(content); i += 2 {
+			if content[i] == 0 {
+				even++
+			}
+			if content[i+1] == 0 {
+				odd++
+			}
+		}
+		pairs := len(content) / 2
+		switch {
+		case odd*10 > pairs*3 && even*10 < pairs:
+			return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "utf-16le"
+		case even*10 > pairs*3 && odd*10 < pairs:
+			return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "utf-16be"
+		}
+	}
+
+	if utf8.Valid(content) {
+		return unicode.UTF8, "utf-8"
+	}
+	if validGB18030(content) {
+		return simplifiedchinese.GB18030, "gb18030"
+	}
+	return charmap.Windows1252, "windows-1252"
+}
+
+// validGB18030 reports whether content is a valid sequence of GB18030 characters: ASCII bytes, two-byte
+// characters as in GBK, and four-byte characters
+func validGB18030(content []byte) bool {
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c < 0x80:
+			i++
+		case c == 0x80 || c == 0xff || i+1 >= len(content):
+			return false
+		case content[i+1] >= 0x40 && content[i+1] <= 0xfe && content[i+1] != 0x7f:
+			i += 2
+		case content[i+1] >= 0x30 && content[i+1] <= 0x39 && i+3 < len(content) &&
+			content[i+2] >= 0x81 && content[i+2] <= 0xfe && content[i+3] >= 0x30 && content[i+3] <= 0x39:
+			i += 4
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLineEndings converts the \r\n and \r line breaks of content to \n
+func normalizeLineEndings(content []byte) []byte {
+	if !bytes.Contains(content, []byte("\r")) {
+		return content
+	}
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+}
+
+// crlfWriter writes to w with every \n replaced by \r\n, the text written to it must not contain \r\n
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c crlfWriter) Write(p []byte) (int, error) {
+	if _, err := c.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
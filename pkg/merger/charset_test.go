@@ -0,0 +1,68 @@
+package merger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		desc     string
+		content  string
+		encoding string
+		want     string
+		wantName string
+	}{
+		{"ASCII", "hello", "", "hello", "utf-8"},
+		{"UTF-8", "hé 中", "auto", "hé 中", "utf-8"},
+		{"UTF-8 with BOM", "\xef\xbb\xbfhé", "", "hé", "utf-8"},
+		{"UTF-16LE with BOM", "\xff\xfeh\x00\xe9\x00", "", "hé", "utf-16le"},
+		{"UTF-16BE with BOM", "\xfe\xff\x00h\x00\xe9", "", "hé", "utf-16be"},
+		{"UTF-16LE without BOM", "h\x00i\x00\n\x00-\x4e", "", "hi\n中", "utf-16le"},
+		{"UTF-16BE without BOM", "\x00h\x00i\x00\n\x4e\x2d", "", "hi\n中", "utf-16be"},
+		{"GBK", "\xd6\xd0\xce\xc4 text", "", "中文 text", "gb18030"},
+		{"GB18030 four-byte character", "ab\x81\x30\x81\x30", "", "ab\u0080", "gb18030"},
+		{"Windows-1252", "caf\xe9", "", "café", "windows-1252"},
+		{"Windows-1252 quotes", "\x93quoted\x94", "", "“quoted”", "windows-1252"},
+		{"named GBK", "\xd6\xd0", "GBK", "中", "gbk"},
+		{"named UTF-16 without BOM", "h\x00i\x00", "utf-16", "hi", "utf-16"},
+		{"named UTF-8 with BOM", "\xef\xbb\xbfhi", "utf-8", "hi", "utf-8"},
+	}
+	for _, tt := range tests {
+		got, name, err := decodeText([]byte(tt.content), tt.encoding)
+		if err != nil {
+			t.Errorf("decodeText(%s) error = %v", tt.desc, err)
+			continue
+		}
+		if string(got) != tt.want || name != tt.wantName {
+			t.Errorf("decodeText(%s) = %q, %s, want %q, %s", tt.desc, got, name, tt.want, tt.wantName)
+		}
+	}
+
+	if _, _, err := decodeText([]byte("hi"), "klingon"); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("decodeText() with an unknown encoding error = %v, want invalid options", err)
+	}
+}
+
+func TestValidGB18030(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"", true},
+		{"ascii", true},
+		{"\xd6\xd0\xce\xc4", true},
+		{"\x81\x30\x81\x30", true},
+		{"\x80", false},
+		{"\xff\x40", false},
+		{"\xd6", false},
+		{"\x81\x7f", false},
+		{"\x81\x30\x81", false},
+		{"\x81\x30\x20\x30", false},
+	}
+	for _, tt := range tests {
+		if got := validGB18030([]byte(tt.content)); got != tt.want {
+			t.Errorf("validGB18030(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
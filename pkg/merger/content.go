@@ -0,0 +1,273 @@
+package merger
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// contentName is a name operand in a content stream, e.g. the font resource of Tf
+type contentName string
+
+// parseContent calls fn for every operator of a PDF content stream or CMap, with its operands.
+// Operands are float64, []byte for strings, contentName, or []any for arrays. Dictionaries and
+// the data of inline images are skipped, malformed syntax is skipped rather than reported.
+func parseContent(data []byte, fn func(op string, args []any)) {
+	p := &contentParser{data: data}
+	var args []any
+	for {
+		kind, value := p.next()
+		switch kind {
+		case tokenEOF:
+			return
+		case tokenOperator:
+			op := value.(string)
+			fn(op, args)
+			args = nil
+			if op == "ID" {
+				p.skipInlineImage()
+			}
+		case tokenOperand:
+			args = append(args, value)
+		}
+	}
+}
+
+// tokenKind is the kind of a token read by contentParser
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenOperand
+	tokenOperator
+	tokenArrayEnd
+)
+
+// contentParser splits content stream syntax into tokens
+type contentParser struct {
+	data []byte
+	pos  int
+}
+
+// isDelimiter reports whether c ends a name, number or operator
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// next returns the next token and, for operands and operators, its value
+func (p *contentParser) next() (tokenKind, any) {
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return tokenEOF, nil
+		}
+
+		switch c := p.data[p.pos]; c {
+		case '(':
+			p.pos++
+			return tokenOperand, p.literalString()
+		case '<':
+			if p.peek(1) == '<' {
+				// Dictionaries only hold marked content properties, which are not needed
+				p.pos += 2
+				continue
+			}
+			p.pos++
+			return tokenOperand, p.hexString()
+		case '[':
+			p.pos++
+			return tokenOperand, p.array()
+		case ']':
+			p.pos++
+			return tokenArrayEnd, nil
+		case '/':
+			p.pos++
+			return tokenOperand, contentName(p.name())
+		case '>', ')', '{', '}':
+			p.pos++
+			continue
+		}
+
+		start := p.pos
+		for p.pos < len(p.data) && !isDelimiter(p.data[p.pos]) {
+			p.pos++
+		}
+		word := string(p.data[start:p.pos])
+		if c := word[0]; c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				return tokenOperand, n
+			}
+		}
+		return tokenOperator, word
+	}
+}
+
+// peek returns the byte offset bytes after the current position, or 0 past the end
+func (p *contentParser) peek(offset int) byte {
+	if p.pos+offset < len(p.data) {
+		return p.data[p.pos+offset]
+	}
+	return 0
+}
+
+// skipSpace skips white space and comments
+func (p *contentParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\r', '\n', '\f', 0:
+			p.pos++
+		case '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\r' && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// literalString reads a string in parentheses, after the opening one
+func (p *contentParser) literalString() []byte {
+	var s []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return s
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return s
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A backslash at the end of a line continues the string on the next line
+				if p.peek(0) == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && p.peek(0) >= '0' && p.peek(0) <= '7'; i++ {
+						n = n*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(n)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s
+}
+
+// hexString reads a string in angle brackets, after the opening one
+func (p *contentParser) hexString() []byte {
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if c := p.data[p.pos]; bytes.IndexByte([]byte("0123456789abcdefABCDEF"), c) >= 0 {
+			digits = append(digits, c)
+		}
+		p.pos++
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, len(digits)/2)
+	for i := range s {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		s[i] = byte(n)
+	}
+	return s
+}
+
+// name reads a name after its slash, decoding #xx escapes
+func (p *contentParser) name() string {
+	var s []byte
+	for p.pos < len(p.data) && !isDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		p.pos++
+		if c == '#' && p.pos+2 <= len(p.data) {
+			if n, err := strconv.ParseUint(string(p.data[p.pos:p.pos+2]), 16, 8); err == nil {
+				c = byte(n)
+				p.pos += 2
+			}
+		}
+		s = append(s, c)
+	}
+	return string(s)
+}
+
+// array reads the operands of an array, after the opening bracket
+func (p *contentParser) array() []any {
+	var a []any
+	for {
+		kind, value := p.next()
+		switch kind {
+		case tokenEOF, tokenArrayEnd:
+			return a
+		case tokenOperand:
+			a = append(a, value)
+		}
+	}
+}
+
+// skipInlineImage skips the data of an inline image, after its ID operator, up to the EI operator
+func (p *contentParser) skipInlineImage() {
+	p.pos++
+	for p.pos+2 <= len(p.data) {
+		if p.data[p.pos] == 'E' && p.data[p.pos+1] == 'I' && isDelimiter(p.data[p.pos-1]) &&
+			(p.pos+2 == len(p.data) || isDelimiter(p.data[p.pos+2])) {
+			p.pos += 2
+			return
+		}
+		p.pos++
+	}
+	p.pos = len(p.data)
+}
+
+// matrix is a PDF transformation matrix [a b c d e f]
+type matrix [6]float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns m × n, the transformation m followed by n
+func (m matrix) multiply(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// translation returns the matrix moving by (x, y)
+func translation(x, y float64) matrix {
+	return matrix{1, 0, 0, 1, x, y}
+}
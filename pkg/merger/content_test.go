@@ -0,0 +1,42 @@
+package merger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseContent(t *testing.T) {
+	type call struct {
+		op   string
+		args []any
+	}
+	tests := []struct {
+		content string
+		want    []call
+	}{
+		{"BT /F1 12 Tf ET", []call{{"BT", nil}, {"Tf", []any{contentName("F1"), 12.0}}, {"ET", nil}}},
+		{"+1.5 -.5 3. 0 0 1 cm", []call{{"cm", []any{1.5, -0.5, 3.0, 0.0, 0.0, 1.0}}}},
+		{`(a\(b\)\n\101\\) Tj`, []call{{"Tj", []any{[]byte("a(b)\nA\\")}}}},
+		{"(a(b)c) Tj", []call{{"Tj", []any{[]byte("a(b)c")}}}},
+		{"(ab\\\ncd\\\r\nef) Tj", []call{{"Tj", []any{[]byte("abcdef")}}}},
+		{`(\0053\7) Tj`, []call{{"Tj", []any{[]byte("\x053\x07")}}}},
+		{"<48 65 6c6C6F> Tj <414> Tj", []call{{"Tj", []any{[]byte("Hello")}}, {"Tj", []any{[]byte("A@")}}}},
+		{"[(A) -250 (B)] TJ", []call{{"TJ", []any{[]any{[]byte("A"), -250.0, []byte("B")}}}}},
+		{"/Im#2F1 Do", []call{{"Do", []any{contentName("Im/1")}}}},
+		{"% comment (not a string\n1 0 0 1 5 6 cm", []call{{"cm", []any{1.0, 0.0, 0.0, 1.0, 5.0, 6.0}}}},
+		{"BI /W 1 /H 1 ID \x00EI\x01 EI Q", []call{
+			{"BI", nil}, {"ID", []any{contentName("W"), 1.0, contentName("H"), 1.0}}, {"Q", nil},
+		}},
+		{"(unterminated", nil},
+		{"[(A) (B)", nil},
+	}
+	for _, tt := range tests {
+		var got []call
+		parseContent([]byte(tt.content), func(op string, args []any) {
+			got = append(got, call{op, args})
+		})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseContent(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
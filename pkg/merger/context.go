@@ -0,0 +1,29 @@
+package merger
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// contextWriter is an io.Writer that fails once ctx is done, so writing a large output stops on cancellation
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
+
+// canceledError returns the error reported when ctx is done during a merge, or nil while ctx is active.
+// The returned error matches context.Canceled or context.DeadlineExceeded with errors.Is.
+func canceledError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("Merge cancelled: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package merger
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Character encodings of simple fonts, used to extract text from fonts without a ToUnicode map
+
+// asciiGlyphNames are the glyph names of the printable ASCII characters other than letters
+var asciiGlyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%',
+	"ampersand": '&', "quotesingle": '\'', "parenleft": '(', "parenright": ')', "asterisk": '*', "plus": '+',
+	"comma": ',', "hyphen": '-', "period": '.', "slash": '/', "zero": '0', "one": '1', "two": '2',
+	"three": '3', "four": '4', "five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+	"colon": ':', "semicolon": ';', "less": '<', "equal": '=', "greater": '>', "question": '?', "at": '@',
+	"bracketleft": '[', "backslash": '\\', "bracketright": ']', "asciicircum": '^', "underscore": '_',
+	"grave": '`', "braceleft": '{', "bar": '|', "braceright": '}', "asciitilde": '~',
+}
+
+// winAnsiHigh are the characters of WinAnsiEncoding from 0x80 to 0x9f, with their glyph names
+var winAnsiHigh = [32]struct {
+	name string
+	r    rune
+}{
+	{"Euro", '€'}, {}, {"quotesinglbase", '‚'}, {"florin", 'ƒ'}, {"quotedblbase", '„'}, {"ellipsis", '…'},
+	{"dagger", '†'}, {"daggerdbl", '‡'}, {"circumflex", 'ˆ'}, {"perthousand", '‰'}, {"Scaron", 'Š'},
+	{"guilsinglleft", '‹'}, {"OE", 'Œ'}, {}, {"Zcaron", 'Ž'}, {}, {}, {"quoteleft", '‘'}, {"quoteright", '’'},
+	{"quotedblleft", '“'}, {"quotedblright", '”'}, {"bullet", '•'}, {"endash", '–'}, {"emdash", '—'},
+	{"tilde", '˜'}, {"trademark", '™'}, {"scaron", 'š'}, {"guilsinglright", '›'}, {"oe", 'œ'}, {},
+	{"zcaron", 'ž'}, {"Ydieresis", 'Ÿ'},
+}
+
+// latin1GlyphNames are the glyph names of the characters from 0xa0 to 0xff, which WinAnsiEncoding shares with Latin-1
+var latin1GlyphNames = strings.Fields(`
+	nbspace exclamdown cent sterling currency yen brokenbar section dieresis copyright ordfeminine
+	guillemotleft logicalnot sfthyphen registered macron degree plusminus twosuperior threesuperior acute
+	mu paragraph periodcentered cedilla onesuperior ordmasculine guillemotright onequarter onehalf
+	threequarters questiondown Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla Egrave Eacute
+	Ecircumflex Edieresis Igrave Iacute Icircumflex Idieresis Eth Ntilde Ograve Oacute Ocircumflex Otilde
+	Odieresis multiply Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls agrave aacute
+	acircumflex atilde adieresis aring ae ccedilla egrave eacute ecircumflex edieresis igrave iacute
+	icircumflex idieresis eth ntilde ograve oacute ocircumflex otilde odieresis divide oslash ugrave uacute
+	ucircumflex udieresis yacute thorn ydieresis`)
+
+// macRomanHigh are the characters of MacRomanEncoding from 0x80 to 0xff
+const macRomanHigh = "ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø" +
+	"¿¡¬√ƒ≈∆«»…\u00a0ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔ\uf8ffÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ"
+
+// extraGlyphNames are glyph names outside WinAnsiEncoding commonly found in Differences arrays
+var extraGlyphNames = map[string]rune{
+	"fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ', "minus": '−', "fraction": '⁄',
+	"dotlessi": 'ı', "Lslash": 'Ł', "lslash": 'ł', "nonbreakingspace": '\u00a0',
+}
+
+// ligatures expands typographic ligatures in extracted text, so that it can be searched
+var ligatures = strings.NewReplacer("ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬅ", "st", "ﬆ", "st")
+
+var (
+	glyphNames       = map[string]rune{}
+	winAnsiEncoding  [256]rune
+	standardEncoding [256]rune
+	macRomanEncoding [256]rune
+)
+
+func init() {
+	for name, r := range asciiGlyphNames {
+		glyphNames[name] = r
+	}
+	for _, g := range winAnsiHigh {
+		if g.name != "" {
+			glyphNames[g.name] = g.r
+		}
+	}
+	for i, name := range latin1GlyphNames {
+		glyphNames[name] = rune(0xa0 + i)
+	}
+	for name, r := range extraGlyphNames {
+		glyphNames[name] = r
+	}
+
+	for c := 0x20; c < 0x7f; c++ {
+		winAnsiEncoding[c] = rune(c)
+		macRomanEncoding[c] = rune(c)
+	}
+	for i, g := range winAnsiHigh {
+		winAnsiEncoding[0x80+i] = g.r
+	}
+	for c := 0xa0; c <= 0xff; c++ {
+		winAnsiEncoding[c] = rune(c)
+	}
+	for i, r := range []rune(macRomanHigh) {
+		macRomanEncoding[0x80+i] = r
+	}
+
+	// StandardEncoding differs from WinAnsiEncoding mostly in its quotes, other differences are rare in practice
+	standardEncoding = winAnsiEncoding
+	standardEncoding['\''] = '’'
+	standardEncoding['`'] = '‘'
+}
+
+// encodingByName returns the character table of a predefined encoding, WinAnsiEncoding for unknown names
+func encodingByName(name string) [256]rune {
+	switch name {
+	case "MacRomanEncoding":
+		return macRomanEncoding
+	case "StandardEncoding":
+		return standardEncoding
+	}
+	return winAnsiEncoding
+}
+
+// glyphRune returns the character of a glyph name, e.g. "adieresis" or "uni00E4"
+func glyphRune(name string) (rune, bool) {
+	// Suffixes name variants of the same character, e.g. "a.sc"
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	// Ligatures list several characters, e.g. "uni00660069", only the first one is used
+	if hex, ok := strings.CutPrefix(name, "uni"); ok && len(hex) >= 4 {
+		if n, err := strconv.ParseUint(hex[:4], 16, 32); err == nil {
+			return rune(n), true
+		}
+	}
+	if hex, ok := strings.CutPrefix(name, "u"); ok && len(hex) >= 4 && len(hex) <= 6 {
+		if n, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return rune(n), true
+		}
+	}
+	return 0, false
+}
+
+// decodeUTF16 decodes the UTF-16BE text of a ToUnicode map entry
+func decodeUTF16(b []byte) string {
+	if len(b)%2 == 1 {
+		return string(b)
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,68 @@
+package merger
+
+import "testing"
+
+func TestGlyphRune(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   rune
+		wantOK bool
+	}{
+		{"A", 'A', true},
+		{"space", ' ', true},
+		{"adieresis", 'ä', true},
+		{"germandbls", 'ß', true},
+		{"Euro", '€', true},
+		{"fi", 'ﬁ', true},
+		{"a.sc", 'a', true},
+		{"uni00E4", 'ä', true},
+		{"uni00660069", 'f', true},
+		{"u1F600", '😀', true},
+		{"uniXYZW", 0, false},
+		{"u12", 0, false},
+		{"notaglyph", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := glyphRune(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("glyphRune(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDecodeUTF16(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"\x00A\x00B", "AB"},
+		{"\x00\xe4\x4e\x2d", "ä中"},
+		{"\xd8\x3d\xde\x00", "😀"},
+		{"", ""},
+		{"abc", "abc"}, // Odd lengths are not UTF-16
+	}
+	for _, tt := range tests {
+		if got := decodeUTF16([]byte(tt.in)); got != tt.want {
+			t.Errorf("decodeUTF16(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodingByName(t *testing.T) {
+	tests := []struct {
+		name string
+		code byte
+		want rune
+	}{
+		{"WinAnsiEncoding", 0x80, '€'},
+		{"WinAnsiEncoding", 0xe4, 'ä'},
+		{"MacRomanEncoding", 0x8a, 'ä'},
+		{"StandardEncoding", 0x27, '’'},
+		{"Unknown", 0x95, '•'},
+	}
+	for _, tt := range tests {
+		if got := encodingByName(tt.name)[tt.code]; got != tt.want {
+			t.Errorf("encodingByName(%s)[%#x] = %q, want %q", tt.name, tt.code, got, tt.want)
+		}
+	}
+}
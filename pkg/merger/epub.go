@@ -0,0 +1,506 @@
+package merger
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v2"
+)
+
+// EPUBOptions stores the metadata of an EPUB book, it can be read from a YAML manifest with ReadEPUBManifest
+type EPUBOptions struct {
+	Title       string   `yaml:"title" json:"title,omitempty"` // Defaults to the front matter title of the first file, then to the output file name
+	Authors     []string `yaml:"authors" json:"authors,omitempty"`
+	Language    string   `yaml:"language" json:"language,omitempty"`     // BCP 47 language tag, defaults to "en"
+	Identifier  string   `yaml:"identifier" json:"identifier,omitempty"` // Defaults to a UUID derived from the title and the input names
+	Publisher   string   `yaml:"publisher" json:"publisher,omitempty"`
+	Description string   `yaml:"description" json:"description,omitempty"`
+	Date        string   `yaml:"date" json:"date,omitempty"`   // Publication date, e.g. 2024-05-01
+	Cover       string   `yaml:"cover" json:"cover,omitempty"` // Cover image file, relative paths in a manifest are relative to it
+}
+
+// ReadEPUBManifest reads book metadata from a YAML file with the keys of EPUBOptions; "author" is accepted
+// for a single author
+func ReadEPUBManifest(path string) (EPUBOptions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return EPUBOptions{}, newError(ErrInputNotFound, err, "Manifest does not exist: %s", path)
+		}
+		return EPUBOptions{}, fmt.Errorf("Failed to read manifest %s: %w", path, err)
+	}
+
+	var manifest struct {
+		EPUBOptions `yaml:",inline"`
+		Author      string `yaml:"author"`
+	}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return EPUBOptions{}, newError(ErrInvalidOptions, err, "Invalid manifest %s: %v", path, err)
+	}
+	opts := manifest.EPUBOptions
+	if manifest.Author != "" {
+		opts.Authors = append([]string{manifest.Author}, opts.Authors...)
+	}
+	if opts.Cover != "" && !filepath.IsAbs(opts.Cover) {
+		opts.Cover = filepath.Join(filepath.Dir(path), opts.Cover)
+	}
+	return opts, nil
+}
+
+// Override returns o with the fields set in other replacing its own, to apply flags on top of a manifest
+func (o EPUBOptions) Override(other EPUBOptions) EPUBOptions {
+	for _, f := range []struct{ dst, src *string }{
+		{&o.Title, &other.Title}, {&o.Language, &other.Language}, {&o.Identifier, &other.Identifier},
+		{&o.Publisher, &other.Publisher}, {&o.Description, &other.Description}, {&o.Date, &other.Date},
+		{&o.Cover, &other.Cover},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	if len(other.Authors) > 0 {
+		o.Authors = other.Authors
+	}
+	return o
+}
+
+// epubChapter is an XHTML document of an EPUB book, made from one Markdown input
+type epubChapter struct {
+	Language string
+	ID       string
+	File     string
+	Title    string
+	Body     string
+	Sections []tocEntry // Headings one level below the chapter title, listed under the chapter in the navigation document
+}
+
+// epubItem is a file of an EPUB book listed in its package manifest
+type epubItem struct {
+	ID         string
+	File       string
+	MediaType  string
+	Properties string
+	data       []byte
+}
+
+// epubBook holds the values of the EPUB templates
+type epubBook struct {
+	EPUBOptions
+	Modified string
+	Chapters []*epubChapter
+	Items    []*epubItem
+	CoverID  string
+}
+
+// mergeEPUB writes the Markdown inputs as an EPUB 3 book to w, with one chapter per input titled with its
+// front matter title or file name, a navigation document and the images the inputs refer to
+func mergeEPUB(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	fail := func(err error) (*MergeResult, error) {
+		return &MergeResult{Success: false, ErrorMessage: err.Error()}, err
+	}
+
+	book := &epubBook{EPUBOptions: opts.EPUB, Modified: time.Now().UTC().Format("2006-01-02T15:04:05Z")}
+	if book.Language == "" {
+		book.Language = "en"
+	}
+	templates, err := parseMarkdownTemplates(opts.Markdown)
+	if err != nil {
+		return fail(err)
+	}
+	md := newMarkdown(true)
+	images := map[string]*epubItem{} // By image file path
+	var warnings []Warning
+
+	addImage := func(path string) (*epubItem, error) {
+		if item, ok := images[path]; ok {
+			return item, nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		mediaType := mime.TypeByExtension(ext)
+		if !strings.HasPrefix(mediaType, "image/") {
+			return nil, fmt.Errorf("%s is not an image", filepath.Base(path))
+		}
+		item := &epubItem{
+			ID:        fmt.Sprintf("image-%d", len(images)+1),
+			File:      fmt.Sprintf("images/image-%d%s", len(images)+1, ext),
+			MediaType: strings.SplitN(mediaType, ";", 2)[0],
+			data:      data,
+		}
+		images[path] = item
+		book.Items = append(book.Items, item)
+		return item, nil
+	}
+
+	for i, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		content, err := readMarkdown(in, opts)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFile) {
+				err = fmt.Errorf("Failed to read file %s: %w", in.displayName(), err)
+			}
+			return fail(err)
+		}
+
+		chapter := &epubChapter{Language: book.Language, ID: fmt.Sprintf("chapter-%03d", i+1), Title: in.title()}
+		chapter.File = chapter.ID + ".xhtml"
+		file := markdownFile(in, i, len(inputs), content)
+		fm, body, err := splitFrontMatter(content)
+		if err == nil {
+			content = body
+		}
+		if title, ok := fm["title"].(string); ok && strings.TrimSpace(title) != "" && in.Title == "" {
+			chapter.Title = strings.TrimSpace(title)
+			if book.Title == "" && i == 0 {
+				book.Title = chapter.Title
+			}
+		}
+		content = addHeadingLevels(content, in.headingLevel()-1)
+
+		// Chapters are separate documents, the separator template does not apply
+		file.Title = chapter.Title
+		header, _, footer, err := renderMarkdownTemplates(templates, file)
+		if err != nil {
+			return fail(err)
+		}
+		switch {
+		case header != "":
+			content = append([]byte(header+"\n\n"), content...)
+		case templates.header == nil && opts.Markdown.AddTitles:
+			content = append([]byte(fmt.Sprintf("%s %s\n\n", strings.Repeat("#", in.headingLevel()), chapter.Title)), content...)
+		}
+		if footer != "" {
+			content = append(content, "\n\n"+footer+"\n"...)
+		}
+
+		root := md.Parser().Parse(text.NewReader(content))
+		var missing []*ast.Image
+		err = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if !entering {
+				return ast.WalkContinue, nil
+			}
+			switch n := n.(type) {
+			case *ast.Heading:
+				id, _ := n.AttributeString("id")
+				if b, ok := id.([]byte); ok && n.Level == in.headingLevel()+1 {
+					chapter.Sections = append(chapter.Sections, tocEntry{Level: 2, ID: string(b), Text: nodeText(n, content)})
+				}
+			case *ast.Image:
+				// Books cannot refer to local files or remote images, images are embedded or replaced by their description
+				if isRemoteImage(string(n.Destination)) {
+					warnings = append(warnings, Warning{Path: in.displayName(), Message: fmt.Sprintf("Image not embedded: %s is not a local file", n.Destination)})
+					missing = append(missing, n)
+					return ast.WalkSkipChildren, nil
+				}
+				if in.Reader != nil {
+					return ast.WalkContinue, nil
+				}
+				path, _, ok := localImagePath(string(n.Destination), in.Path)
+				if !ok {
+					return ast.WalkContinue, nil
+				}
+				item, err := addImage(path)
+				if err != nil {
+					warnings = append(warnings, Warning{Path: in.displayName(), Message: fmt.Sprintf("Image not embedded: %v", err)})
+					missing = append(missing, n)
+					return ast.WalkSkipChildren, nil
+				}
+				n.Destination = []byte(item.File)
+			}
+			return ast.WalkContinue, nil
+		})
+		if err != nil {
+			return fail(err)
+		}
+		for _, img := range missing {
+			img.Parent().ReplaceChild(img.Parent(), img, ast.NewString([]byte(nodeText(img, content))))
+		}
+
+		var html bytes.Buffer
+		if err := md.Renderer().Render(&html, content, root); err != nil {
+			return fail(fmt.Errorf("Failed to render %s: %w", in.displayName(), err))
+		}
+		chapter.Body = html.String()
+		book.Chapters = append(book.Chapters, chapter)
+		opts.Progress.report(ProgressEvent{Stage: ProgressMerged, File: in.displayName(), Current: i + 1, Total: len(inputs)})
+	}
+
+	if book.Title == "" {
+		book.Title = "Book"
+		if opts.OutputFile != "" {
+			book.Title = strings.TrimSuffix(filepath.Base(opts.OutputFile), filepath.Ext(opts.OutputFile))
+		}
+	}
+	if book.Identifier == "" {
+		book.Identifier = bookIdentifier(book.Title, inputs)
+	}
+	if book.Cover != "" {
+		item, err := addImage(book.Cover)
+		if err != nil {
+			return fail(newError(ErrInvalidOptions, err, "Cannot read cover image: %v", err))
+		}
+		item.Properties = "cover-image"
+		book.CoverID = item.ID
+	}
+
+	if err := writeEPUB(&progressWriter{w: w, progress: opts.Progress}, book); err != nil {
+		if !errors.Is(err, ErrOutputFailed) {
+			err = newError(ErrOutputFailed, err, "Cannot write output: %v", err)
+		}
+		return fail(err)
+	}
+
+	return &MergeResult{
+		Success:     true,
+		MergedFiles: len(inputs),
+		FilesList:   inputNames(inputs),
+		Warnings:    warnings,
+	}, nil
+}
+
+// isRemoteImage reports whether the image destination dest is a URL outside the book, which EPUB reading systems do not load
+func isRemoteImage(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+	return u.Host != "" || strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "https")
+}
+
+// bookIdentifier returns a UUID derived from the title and the input names, so that rebuilding a book keeps its identifier
+func bookIdentifier(title string, inputs []Input) string {
+	h := sha1.New()
+	io.WriteString(h, title)
+	for _, in := range inputs {
+		io.WriteString(h, "\x00"+filepath.Base(in.displayName()))
+	}
+	sum := h.Sum(nil)
+	sum[6] = sum[6]&0x0f | 0x50 // Version 5, name-based with SHA-1
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// writeEPUB writes the files of book as an EPUB container
+func writeEPUB(w io.Writer, book *epubBook) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+
+	// The mimetype file comes first and uncompressed, so that the type can be read at a fixed offset
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: now})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	highlight, err := highlightCSS()
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		name     string
+		template *template.Template
+		data     any
+	}{
+		{"META-INF/container.xml", epubContainerTemplate, book},
+		{"OEBPS/content.opf", epubPackageTemplate, book},
+		{"OEBPS/nav.xhtml", epubNavTemplate, book},
+		{"OEBPS/toc.ncx", epubNCXTemplate, book},
+	}
+	for _, f := range files {
+		fw, err := create(f.name)
+		if err != nil {
+			return err
+		}
+		if err := f.template.Execute(fw, f.data); err != nil {
+			return err
+		}
+	}
+	for _, chapter := range book.Chapters {
+		fw, err := create("OEBPS/" + chapter.File)
+		if err != nil {
+			return err
+		}
+		if err := epubChapterTemplate.Execute(fw, chapter); err != nil {
+			return err
+		}
+	}
+	fw, err := create("OEBPS/style.css")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(fw, epubStyle+"\n"+highlight); err != nil {
+		return err
+	}
+	for _, item := range book.Items {
+		fw, err := create("OEBPS/" + item.File)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(item.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// xmlEscape escapes s for XML text and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func newEPUBTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(template.FuncMap{
+		"xml": xmlEscape,
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(text))
+}
+
+var epubContainerTemplate = newEPUBTemplate("container", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`)
+
+var epubPackageTemplate = newEPUBTemplate("package", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{xml .Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{xml .Identifier}}</dc:identifier>
+    <dc:title>{{xml .Title}}</dc:title>
+    <dc:language>{{xml .Language}}</dc:language>
+{{- range .Authors}}
+    <dc:creator>{{xml .}}</dc:creator>
+{{- end}}
+{{- if .Publisher}}
+    <dc:publisher>{{xml .Publisher}}</dc:publisher>
+{{- end}}
+{{- if .Description}}
+    <dc:description>{{xml .Description}}</dc:description>
+{{- end}}
+{{- if .Date}}
+    <dc:date>{{xml .Date}}</dc:date>
+{{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+{{- if .CoverID}}
+    <meta name="cover" content="{{.CoverID}}"/>
+{{- end}}
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+{{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.File}}" media-type="application/xhtml+xml"/>
+{{- end}}
+{{- range .Items}}
+    <item id="{{.ID}}" href="{{.File}}" media-type="{{.MediaType}}"{{if .Properties}} properties="{{.Properties}}"{{end}}/>
+{{- end}}
+  </manifest>
+  <spine toc="ncx">
+{{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+{{- end}}
+  </spine>
+</package>
+`)
+
+var epubNavTemplate = newEPUBTemplate("nav", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{xml .Language}}" lang="{{xml .Language}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{xml .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>Contents</h1>
+    <ol>
+{{- range .Chapters}}
+{{- $file := .File}}
+      <li><a href="{{.File}}">{{xml .Title}}</a>
+{{- if .Sections}}
+        <ol>
+{{- range .Sections}}
+          <li><a href="{{$file}}#{{xml .ID}}">{{xml .Text}}</a></li>
+{{- end}}
+        </ol>
+{{- end}}
+      </li>
+{{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`)
+
+var epubNCXTemplate = newEPUBTemplate("ncx", `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="{{xml .Identifier}}"/>
+  </head>
+  <docTitle><text>{{xml .Title}}</text></docTitle>
+  <navMap>
+{{- range $i, $c := .Chapters}}
+    <navPoint id="nav-{{$c.ID}}" playOrder="{{inc $i}}">
+      <navLabel><text>{{xml $c.Title}}</text></navLabel>
+      <content src="{{$c.File}}"/>
+    </navPoint>
+{{- end}}
+  </navMap>
+</ncx>
+`)
+
+var epubChapterTemplate = newEPUBTemplate("chapter", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{xml .Language}}" lang="{{xml .Language}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{xml .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<section epub:type="chapter">
+{{.Body}}</section>
+</body>
+</html>
+`)
+
+// epubStyle is the stylesheet of EPUB books, kept simple so that readers can apply their own fonts and sizes
+const epubStyle = `body { margin: 0 5%; line-height: 1.5; }
+h1, h2, h3, h4, h5, h6 { line-height: 1.25; page-break-after: avoid; }
+img { max-width: 100%; }
+blockquote { margin-left: 0; padding-left: 1em; border-left: 3px solid #ccc; color: #555; }
+code { font-family: monospace; font-size: 0.9em; }
+pre { padding: 0.8em; overflow-x: auto; white-space: pre-wrap; background: #f6f8fa; font-size: 0.85em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 0.6em; border: 1px solid #ccc; }
+nav ol { list-style: none; }`
@@ -0,0 +1,63 @@
+package merger
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEPUBImages(t *testing.T) {
+	dir := t.TempDir()
+	chapter := "# Images\n\n![Local](logo.png)\n\n![Remote](https://example.com/logo.png)\n\n" +
+		"![Scheme-relative](//example.com/logo.png)\n\n![Missing](missing.png)\n"
+	if err := os.WriteFile(filepath.Join(dir, "chapter.md"), []byte(chapter), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	result, err := MergeContext(context.Background(), Options{InputDir: dir, Output: &out, Format: FormatEPUB})
+	if err != nil {
+		t.Fatalf("MergeContext() error = %v", err)
+	}
+	if len(result.Warnings) != 3 {
+		t.Errorf("MergeContext() warnings = %v, want the remote and missing images", result.Warnings)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("MergeContext() wrote an unreadable book: %v", err)
+	}
+	var body string
+	for _, f := range zr.File {
+		if f.Name == "OEBPS/chapter-001.xhtml" {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			body = string(content)
+		}
+	}
+
+	// Only the local image is kept, the others are replaced by their description
+	if !strings.Contains(body, `<img src="images/image-1.png" alt="Local"`) {
+		t.Errorf("chapter does not embed the local image:\n%s", body)
+	}
+	if strings.Contains(body, "example.com") || strings.Contains(body, "missing.png") {
+		t.Errorf("chapter refers to an image outside the book:\n%s", body)
+	}
+	for _, alt := range []string{"<p>Remote</p>", "<p>Scheme-relative</p>", "<p>Missing</p>"} {
+		if !strings.Contains(body, alt) {
+			t.Errorf("chapter does not contain %s:\n%s", alt, body)
+		}
+	}
+}
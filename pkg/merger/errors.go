@@ -0,0 +1,96 @@
+package merger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by merge operations, usable with errors.Is
+var (
+	// ErrNoInputFiles is returned when no input files were provided or found
+	ErrNoInputFiles = errors.New("No input files")
+	// ErrInputNotFound is returned when an input directory or file does not exist
+	ErrInputNotFound = errors.New("Input not found")
+	// ErrInputNotDir is returned when the input path is not a directory
+	ErrInputNotDir = errors.New("Input is not a directory")
+	// ErrNoValidFiles is returned when none of the input files can be merged
+	ErrNoValidFiles = errors.New("No valid files to merge")
+	// ErrUnsupportedFileType is returned for files of a type that cannot be processed
+	ErrUnsupportedFileType = errors.New("Unsupported file type")
+	// ErrInvalidFile is matched by every *InvalidFileError
+	ErrInvalidFile = errors.New("Invalid file")
+	// ErrMergeFailed is returned when the merge itself fails after the inputs were accepted
+	ErrMergeFailed = errors.New("Merge failed")
+	// ErrOutputFailed is returned when the output file cannot be created or written
+	ErrOutputFailed = errors.New("Cannot write output")
+	// ErrOutputExists is returned when the output file exists and Options.IfExists does not allow replacing it
+	ErrOutputExists = errors.New("Output file already exists")
+	// ErrInvalidOptions is returned when merge options are invalid, e.g. an unknown sort order
+	ErrInvalidOptions = errors.New("Invalid options")
+	// ErrInvalidTempDir is returned when a path is not a temporary directory created by this package
+	ErrInvalidTempDir = errors.New("Not a valid temporary directory")
+)
+
+// InvalidFileError reports an input file that failed validation
+type InvalidFileError struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (e *InvalidFileError) Error() string {
+	return fmt.Sprintf("Invalid file %s: %s", e.Path, e.Reason)
+}
+
+// Is reports whether target is ErrInvalidFile, so errors.Is(err, ErrInvalidFile) matches any invalid file
+func (e *InvalidFileError) Is(target error) bool {
+	return target == ErrInvalidFile
+}
+
+// InvalidFiles returns every *InvalidFileError contained in err, including errors joined with errors.Join
+func InvalidFiles(err error) []*InvalidFileError {
+	var invalid []*InvalidFileError
+	var walk func(error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case nil:
+		case *InvalidFileError:
+			invalid = append(invalid, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				walk(inner)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return invalid
+}
+
+// mergeError is an error with a descriptive message that matches a sentinel error and,
+// optionally, the underlying cause with errors.Is and errors.As
+type mergeError struct {
+	msg   string
+	kind  error
+	cause error
+}
+
+func (e *mergeError) Error() string {
+	return e.msg
+}
+
+func (e *mergeError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.cause}
+}
+
+// newError creates an error of the given kind with a formatted message
+func newError(kind error, cause error, format string, args ...interface{}) error {
+	return &mergeError{
+		msg:   fmt.Sprintf(format, args...),
+		kind:  kind,
+		cause: cause,
+	}
+}
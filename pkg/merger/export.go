@@ -0,0 +1,348 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ExportFormat is the format ExportPDF converts the text of a PDF file to
+type ExportFormat string
+
+const (
+	// ExportMarkdown writes paragraphs, with headings inferred from font sizes and bullets as list items
+	ExportMarkdown ExportFormat = "markdown"
+	// ExportText writes paragraphs as plain text
+	ExportText ExportFormat = "text"
+)
+
+// ParseExportFormat converts a string to an ExportFormat, an empty string leaves the format to be
+// inferred from the output file name
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return "", nil
+	case "markdown", "md":
+		return ExportMarkdown, nil
+	case "text", "txt":
+		return ExportText, nil
+	}
+	return "", newError(ErrInvalidOptions, nil, "Unknown export format %q, must be markdown or text", s)
+}
+
+// exportFormatForPath returns the export format of an output file name, Markdown unless it ends in .txt
+func exportFormatForPath(path string) ExportFormat {
+	if strings.EqualFold(filepath.Ext(path), ".txt") {
+		return ExportText
+	}
+	return ExportMarkdown
+}
+
+// ExportOptions selects the pages ExportPDF reads and how their text is written
+type ExportOptions struct {
+	Format      ExportFormat // Output format, inferred from the output file name when empty
+	Pages       string       // Page range expression, e.g. "1-3,8", all pages when empty
+	PageMarkers bool         // Write a marker before the text of each page
+}
+
+// ExportResult stores the result of an export
+type ExportResult struct {
+	Success          bool         `json:"success"`
+	OutputFile       string       `json:"outputFile,omitempty"`
+	Format           ExportFormat `json:"format,omitempty"`
+	Pages            []int        `json:"pages,omitempty"`            // Exported pages
+	Headings         int          `json:"headings,omitempty"`         // Headings found, Markdown only
+	PagesWithoutText []int        `json:"pagesWithoutText,omitempty"` // Exported pages without extractable text, e.g. scans
+	ErrorMessage     string       `json:"errorMessage,omitempty"`
+}
+
+// ExportPDF converts the text of the PDF file at path to plain text or rough Markdown and writes it to
+// outputFile. Lines are joined into paragraphs by their spacing and indentation, and in Markdown, short
+// paragraphs set in a larger font than the body text become headings, larger fonts giving higher levels.
+func ExportPDF(path, outputFile string, opts ExportOptions) (*ExportResult, error) {
+	return ExportPDFContext(context.Background(), path, outputFile, opts)
+}
+
+// ExportPDFContext is ExportPDF stopping early when ctx is cancelled
+func ExportPDFContext(ctx context.Context, path, outputFile string, opts ExportOptions) (*ExportResult, error) {
+	if opts.Format == "" {
+		opts.Format = exportFormatForPath(outputFile)
+	}
+
+	var buf bytes.Buffer
+	result, err := ExportPDFStream(ctx, Input{Path: path}, &buf, opts)
+	if err != nil {
+		return result, err
+	}
+	if err := writeFile(outputFile, &buf); err != nil {
+		return &ExportResult{Success: false, ErrorMessage: err.Error()}, err
+	}
+	result.OutputFile = outputFile
+	return result, nil
+}
+
+// ExportPDFStream is ExportPDF reading the PDF document from in and writing the text to w,
+// as Markdown when opts.Format is empty
+func ExportPDFStream(ctx context.Context, in Input, w io.Writer, opts ExportOptions) (*ExportResult, error) {
+	fail := func(err error) (*ExportResult, error) {
+		return &ExportResult{Success: false, ErrorMessage: err.Error()}, err
+	}
+	if opts.Format == "" {
+		opts.Format = ExportMarkdown
+	}
+	if opts.Format != ExportMarkdown && opts.Format != ExportText {
+		return fail(newError(ErrInvalidOptions, nil, "Unknown export format %q, must be markdown or text", opts.Format))
+	}
+
+	pdf, err := readPDFDocument(ctx, in, model.VALIDATE)
+	if err != nil {
+		return fail(err)
+	}
+	pages, err := selectedPages(opts.Pages, pdf.PageCount)
+	if err != nil {
+		return fail(err)
+	}
+
+	e := newTextExtractor(pdf)
+	pageLines := make([][]textLine, len(pages))
+	for i, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		lines, err := e.pageLines(page)
+		if err != nil {
+			return fail(newError(ErrInvalidFile, err, "Cannot read the text of page %d: %s", page, cleanPDFError(err)))
+		}
+		pageLines[i] = lines
+	}
+
+	result := &ExportResult{Success: true, Format: opts.Format, Pages: pages}
+	markdown := opts.Format == ExportMarkdown
+	blocks := make([][]*textBlock, len(pages))
+	for i, lines := range pageLines {
+		blocks[i] = textBlocks(lines)
+		if len(blocks[i]) == 0 {
+			result.PagesWithoutText = append(result.PagesWithoutText, pages[i])
+		}
+	}
+	if markdown {
+		result.Headings = markHeadings(blocks, bodySize(pageLines))
+	}
+
+	var buf bytes.Buffer
+	for i, page := range pages {
+		if opts.PageMarkers {
+			if markdown {
+				fmt.Fprintf(&buf, "<!-- page %d -->\n\n", page)
+			} else {
+				fmt.Fprintf(&buf, "--- Page %d ---\n\n", page)
+			}
+		}
+		for _, block := range blocks[i] {
+			if markdown {
+				buf.WriteString(block.markdown())
+			} else {
+				buf.WriteString(block.text())
+			}
+			buf.WriteString("\n\n")
+		}
+	}
+	// Blocks and pages are separated by blank lines, the output ends with a single newline
+	out := bytes.TrimRight(buf.Bytes(), "\n")
+	if len(out) > 0 {
+		out = append(out, '\n')
+	}
+	if _, err := w.Write(out); err != nil {
+		return fail(newError(ErrOutputFailed, err, "Failed to write export: %v", err))
+	}
+	return result, nil
+}
+
+// textBlock is a paragraph or heading assembled from consecutive lines of a page
+type textBlock struct {
+	lines []textLine
+	level int // Heading level, 0 for paragraphs
+}
+
+// textBlocks groups the lines of a page into blocks, a line continues the current block when it is set in
+// the same font size, directly below the previous line, and neither starts a new indented paragraph nor
+// follows a line ending short of the block width
+func textBlocks(lines []textLine) []*textBlock {
+	var blocks []*textBlock
+	var current *textBlock
+	for _, line := range lines {
+		if strings.TrimSpace(line.text) == "" {
+			continue
+		}
+		if current != nil && current.continues(line) {
+			current.lines = append(current.lines, line)
+			continue
+		}
+		current = &textBlock{lines: []textLine{line}}
+		blocks = append(blocks, current)
+	}
+	return blocks
+}
+
+// continues reports whether line is the next line of the block
+func (b *textBlock) continues(line textLine) bool {
+	prev := b.lines[len(b.lines)-1]
+	size := max(prev.size, line.size)
+	if size <= 0 || math.Abs(prev.size-line.size) > 0.1*size {
+		return false
+	}
+	if gap := prev.y - line.y; gap <= 0 || gap > 1.6*size {
+		return false
+	}
+	if line.x > prev.x+size {
+		return false
+	}
+	right := line.endX
+	for _, l := range b.lines {
+		right = max(right, l.endX)
+	}
+	return prev.endX >= right-5*size
+}
+
+// size returns the largest font size of the block
+func (b *textBlock) size() float64 {
+	size := 0.0
+	for _, line := range b.lines {
+		size = max(size, line.size)
+	}
+	return size
+}
+
+// content returns the lines of the block joined with spaces, words hyphenated at line ends are rejoined
+func (b *textBlock) content() string {
+	var text string
+	for i, line := range b.lines {
+		s := strings.TrimSpace(line.text)
+		if i == 0 {
+			text = s
+			continue
+		}
+		if before, ok := strings.CutSuffix(text, "-"); ok && endsWithLetter(before) && startsWithLower(s) {
+			text = before + s
+			continue
+		}
+		text += " " + s
+	}
+	return text
+}
+
+// text returns the block as plain text
+func (b *textBlock) text() string {
+	return b.content()
+}
+
+// markdown returns the block as a Markdown heading, list item or paragraph
+func (b *textBlock) markdown() string {
+	text := b.content()
+	if b.level > 0 {
+		return strings.Repeat("#", b.level) + " " + text
+	}
+	if r, n := utf8.DecodeRuneInString(text); isBullet(r) {
+		return "- " + strings.TrimSpace(text[n:])
+	}
+	// Text that would start a heading or a quote is escaped
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(text, ">") {
+		return `\` + text
+	}
+	return text
+}
+
+// bodySize returns the font size of most of the text, rounded to half a point
+func bodySize(pages [][]textLine) float64 {
+	chars := map[float64]int{}
+	for _, lines := range pages {
+		for _, line := range lines {
+			chars[math.Round(line.size*2)/2] += utf8.RuneCountInString(line.text)
+		}
+	}
+	size, count := 0.0, 0
+	for s, n := range chars {
+		if n > count || n == count && s < size {
+			size, count = s, n
+		}
+	}
+	return size
+}
+
+// markHeadings sets the level of the blocks that look like headings: short blocks with letters, set at least
+// 8% larger than the body text. Sizes shared by several headings are levels, the largest being level 1, and
+// other headings take the level of the next smaller of those sizes, so that one-off sizes on title pages do not
+// push section headings down. It returns the number of headings.
+func markHeadings(pages [][]*textBlock, body float64) int {
+	var headings []*textBlock
+	counts := map[float64]int{}
+	for _, blocks := range pages {
+		for _, block := range blocks {
+			text := block.content()
+			if body <= 0 || block.size() < 1.08*body || len(block.lines) > 3 || utf8.RuneCountInString(text) > 200 ||
+				strings.IndexFunc(text, unicode.IsLetter) < 0 {
+				continue
+			}
+			headings = append(headings, block)
+			counts[headingSize(block)]++
+		}
+	}
+
+	var sizes []float64
+	for size, n := range counts {
+		if n > 1 {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		for size := range counts {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+	for _, block := range headings {
+		size := headingSize(block)
+		level := len(sizes)
+		for i, s := range sizes {
+			if s <= size {
+				level = i + 1
+				break
+			}
+		}
+		block.level = min(level, 6)
+	}
+	return len(headings)
+}
+
+// headingSize returns the font size of a heading rounded to half a point
+func headingSize(b *textBlock) float64 {
+	return math.Round(b.size()*2) / 2
+}
+
+// isBullet reports whether r is a list bullet, symbol fonts often map bullets to the private use area
+func isBullet(r rune) bool {
+	switch r {
+	case '•', '◦', '▪', '‣', '●', '■', '○':
+		return true
+	}
+	return r >= 0xe000 && r <= 0xf8ff
+}
+
+func endsWithLetter(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsLetter(r)
+}
+
+func startsWithLower(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLower(r)
+}
@@ -0,0 +1,73 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// text.pdf has two 20 pt headings with 12 pt paragraphs on page 1, text in a font with a Differences
+// encoding and a bullet on page 2, and no text on page 3
+func TestExportPDF(t *testing.T) {
+	tests := []struct {
+		desc         string
+		opts         ExportOptions
+		want         string
+		wantHeadings int
+	}{
+		{
+			desc: "Markdown with page markers",
+			opts: ExportOptions{Format: ExportMarkdown, PageMarkers: true},
+			want: "<!-- page 1 -->\n\n# Introduction\n\nThis is the first paragraph of the text.\n\n# Details\n\nBody text\n\n" +
+				"<!-- page 2 -->\n\nMärchen Straße\n\n- item\n\n<!-- page 3 -->\n",
+			wantHeadings: 2,
+		},
+		{
+			desc: "text with page markers",
+			opts: ExportOptions{Format: ExportText, PageMarkers: true},
+			want: "--- Page 1 ---\n\nIntroduction\n\nThis is the first paragraph of the text.\n\nDetails\n\nBody text\n\n" +
+				"--- Page 2 ---\n\nMärchen Straße\n\n• item\n\n--- Page 3 ---\n",
+		},
+		{
+			desc: "selected pages without markers",
+			opts: ExportOptions{Format: ExportMarkdown, Pages: "2,3"},
+			want: "Märchen Straße\n\n- item\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		result, err := ExportPDFStream(context.Background(), Input{Path: filepath.Join("testdata", "text.pdf")}, &buf, tt.opts)
+		if err != nil {
+			t.Errorf("export %s error = %v", tt.desc, err)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("export %s = %q, want %q", tt.desc, got, tt.want)
+		}
+		if result.Headings != tt.wantHeadings {
+			t.Errorf("export %s headings = %d, want %d", tt.desc, result.Headings, tt.wantHeadings)
+		}
+		if !slices.Equal(result.PagesWithoutText, []int{3}) {
+			t.Errorf("export %s pages without text = %v, want [3]", tt.desc, result.PagesWithoutText)
+		}
+	}
+}
+
+func TestExportFormatForPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want ExportFormat
+	}{
+		{"out.txt", ExportText},
+		{"OUT.TXT", ExportText},
+		{"out.md", ExportMarkdown},
+		{"out", ExportMarkdown},
+	}
+	for _, tt := range tests {
+		if got := exportFormatForPath(tt.path); got != tt.want {
+			t.Errorf("exportFormatForPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
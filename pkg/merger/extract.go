@@ -0,0 +1,199 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ExtractOptions selects the pages Extract reads and what it writes, at least one output must be set
+type ExtractOptions struct {
+	Pages      string // Page range expression, e.g. "1-3,8", all pages when empty
+	OutputFile string // New PDF file with the selected pages, in the order of Pages
+	ImagesDir  string // Directory the images embedded in the selected pages are written to
+	TextFile   string // Text of the selected pages, as JSON if the name ends in .json, otherwise as plain text
+}
+
+// ExtractedImage describes an image written by Extract
+type ExtractedImage struct {
+	Page   int    `json:"page"`
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ExtractResult stores the result of an extraction
+type ExtractResult struct {
+	Success          bool             `json:"success"`
+	Pages            []int            `json:"pages,omitempty"` // Selected pages
+	OutputFile       string           `json:"outputFile,omitempty"`
+	ImagesDir        string           `json:"imagesDir,omitempty"`
+	Images           []ExtractedImage `json:"images,omitempty"`
+	TextFile         string           `json:"textFile,omitempty"`
+	PagesWithoutText []int            `json:"pagesWithoutText,omitempty"` // Selected pages without extractable text, e.g. scans
+	ErrorMessage     string           `json:"errorMessage,omitempty"`
+}
+
+// Extract reads the pages of the PDF file at path selected by opts.Pages and writes them to a new PDF file,
+// their embedded images to a directory and their text to a file, as set in opts
+func Extract(path string, opts ExtractOptions) (*ExtractResult, error) {
+	return ExtractContext(context.Background(), path, opts)
+}
+
+// ExtractContext is Extract stopping early when ctx is cancelled
+func ExtractContext(ctx context.Context, path string, opts ExtractOptions) (*ExtractResult, error) {
+	fail := func(err error) (*ExtractResult, error) {
+		return &ExtractResult{Success: false, ErrorMessage: err.Error()}, err
+	}
+	if opts.OutputFile == "" && opts.ImagesDir == "" && opts.TextFile == "" {
+		return fail(newError(ErrInvalidOptions, nil, "Nothing to extract, set an output file, an images directory or a text file"))
+	}
+
+	pdf, err := readPDFFile(ctx, path, model.EXTRACTPAGES)
+	if err != nil {
+		return fail(err)
+	}
+	pages, err := selectedPages(opts.Pages, pdf.PageCount)
+	if err != nil {
+		return fail(err)
+	}
+	result := &ExtractResult{Success: true, Pages: pages}
+
+	// Text and images are read before the pages are copied into the new document
+	if opts.TextFile != "" {
+		texts, err := extractPagesText(ctx, pdf, pages)
+		if err != nil {
+			return fail(err)
+		}
+		if err := writeTextFile(opts.TextFile, texts); err != nil {
+			return fail(err)
+		}
+		result.TextFile = opts.TextFile
+		for _, text := range texts {
+			if text.Text == "" {
+				result.PagesWithoutText = append(result.PagesWithoutText, text.Page)
+			}
+		}
+	}
+
+	if opts.ImagesDir != "" {
+		images, err := extractImages(ctx, pdf, pages, filepath.Base(path), opts.ImagesDir)
+		if err != nil {
+			return fail(err)
+		}
+		result.ImagesDir = opts.ImagesDir
+		result.Images = images
+	}
+
+	if opts.OutputFile != "" {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+		dest, err := pdfcpu.ExtractPages(pdf, pages, false)
+		if err != nil {
+			return fail(newError(ErrInvalidFile, err, "Cannot extract pages of %s: %s", path, cleanPDFError(err)))
+		}
+		if err := writePDFFile(dest, opts.OutputFile); err != nil {
+			return fail(err)
+		}
+		result.OutputFile = opts.OutputFile
+	}
+
+	return result, nil
+}
+
+// extractImages writes the images embedded in pages to dir, named after the PDF file name, the page and the image
+// object number. The resource names of the PDF are not used in file names, they can contain path separators.
+func extractImages(ctx context.Context, pdf *model.Context, pages []int, name, dir string) ([]ExtractedImage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, newError(ErrOutputFailed, err, "Failed to create images directory: %v", err)
+	}
+	// Images are found through the optimization pass, which indexes them by page
+	if err := api.OptimizeContext(pdf); err != nil {
+		return nil, newError(ErrInvalidFile, err, "Cannot read images: %s", cleanPDFError(err))
+	}
+
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	var images []ExtractedImage
+	for _, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := pdfcpu.ExtractPageImages(pdf, page, false)
+		if err != nil {
+			return nil, newError(ErrInvalidFile, err, "Cannot read the images of page %d: %s", page, cleanPDFError(err))
+		}
+
+		objNrs := make([]int, 0, len(found))
+		for objNr := range found {
+			objNrs = append(objNrs, objNr)
+		}
+		sort.Ints(objNrs)
+		for _, objNr := range objNrs {
+			img := found[objNr]
+			// Images in unsupported formats are skipped
+			if img.Reader == nil {
+				continue
+			}
+			resource := strconv.Itoa(objNr)
+			if img.Thumb {
+				resource = "thumb"
+			}
+			path := filepath.Join(dir, fmt.Sprintf("%s_%d_%s.%s", name, page, resource, img.FileType))
+			if rel, err := filepath.Rel(dir, path); err != nil || !filepath.IsLocal(rel) {
+				return nil, newError(ErrInvalidFile, nil, "Cannot write image %d of page %d outside %s", objNr, page, dir)
+			}
+			if err := writeFile(path, img); err != nil {
+				return nil, err
+			}
+			images = append(images, ExtractedImage{Page: page, Path: path, Width: img.Width, Height: img.Height})
+		}
+	}
+	return images, nil
+}
+
+// writeTextFile writes the text of pages to path, as JSON if its name ends in .json, otherwise as plain text
+// with pages separated by form feeds
+func writeTextFile(path string, texts []PageText) error {
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(texts); err != nil {
+			return err
+		}
+	} else {
+		for i, text := range texts {
+			if i > 0 {
+				buf.WriteString("\f")
+			}
+			buf.WriteString(text.Text)
+			buf.WriteString("\n")
+		}
+	}
+	return writeFile(path, &buf)
+}
+
+// writeFile writes r to path through a temporary file
+func writeFile(path string, r io.Reader) error {
+	file, err := createAtomicFile(path, ExistingOverwrite)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.abort()
+		return err
+	}
+	return file.commit()
+}
@@ -0,0 +1,71 @@
+package merger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExtractImagesStayInDir(t *testing.T) {
+	// The image resource of the page is named "Im/../../pwned"
+	out := t.TempDir()
+	dir := filepath.Join(out, "imgs")
+	result, err := Extract(filepath.Join("testdata", "image-resource-name.pdf"), ExtractOptions{ImagesDir: dir})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if len(result.Images) != 1 {
+		t.Fatalf("Extract() wrote %d images, want 1", len(result.Images))
+	}
+	if got, want := result.Images[0].Path, filepath.Join(dir, "image-resource-name_1_5.png"); got != want {
+		t.Errorf("Extract() image path = %s, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "pwned.png")); !os.IsNotExist(err) {
+		t.Errorf("Extract() wrote an image outside the images directory")
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	dir := t.TempDir()
+	pdf := filepath.Join("testdata", "text.pdf")
+	pages := []PageText{
+		{Page: 1, Text: "Introduction\nThis is the first para-\ngraph of the text.\nDetails\nBody text"},
+		{Page: 2, Text: "Märchen Straße\n• item"},
+		{Page: 3, Text: ""},
+	}
+
+	// Pages are separated by form feeds in plain text
+	textFile := filepath.Join(dir, "text.txt")
+	result, err := Extract(pdf, ExtractOptions{TextFile: textFile})
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !slices.Equal(result.PagesWithoutText, []int{3}) {
+		t.Errorf("Extract() pages without text = %v, want [3]", result.PagesWithoutText)
+	}
+	content, err := os.ReadFile(textFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := pages[0].Text + "\n\f" + pages[1].Text + "\n\f\n"; string(content) != want {
+		t.Errorf("Extract() text file = %q, want %q", content, want)
+	}
+
+	jsonFile := filepath.Join(dir, "text.json")
+	if _, err := Extract(pdf, ExtractOptions{Pages: "2,1", TextFile: jsonFile}); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	content, err = os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []PageText
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("Extract() wrote invalid JSON: %v", err)
+	}
+	if want := []PageText{pages[1], pages[0]}; !slices.Equal(got, want) {
+		t.Errorf("Extract() JSON text = %v, want %v", got, want)
+	}
+}
@@ -1,8 +1,11 @@
 package merger
 
 import (
+	"archive/zip"
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
@@ -33,7 +36,7 @@ func CreateTempDirectory() (string, error) {
 
 	// Create the directory
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return "", fmt.Errorf("Failed to create temporary directory: %v", err)
+		return "", newError(ErrOutputFailed, err, "Failed to create temporary directory: %v", err)
 	}
 
 	return tempDir, nil
@@ -48,7 +51,7 @@ func SaveUploadedFile(fileReader io.Reader, fileName string, tempDir string) (*F
 		if err != nil {
 			return &FileUploadResult{
 				Success:      false,
-				ErrorMessage: fmt.Sprintf("Failed to create temporary directory: %v", err),
+				ErrorMessage: err.Error(),
 			}, err
 		}
 	}
@@ -57,10 +60,11 @@ func SaveUploadedFile(fileReader io.Reader, fileName string, tempDir string) (*F
 	filePath := filepath.Join(tempDir, fileName)
 	file, err := os.Create(filePath)
 	if err != nil {
+		err = newError(ErrOutputFailed, err, "Failed to create file: %v", err)
 		return &FileUploadResult{
 			Success:      false,
 			TempDir:      tempDir,
-			ErrorMessage: fmt.Sprintf("Failed to create file: %v", err),
+			ErrorMessage: err.Error(),
 		}, err
 	}
 	defer file.Close()
@@ -68,10 +72,11 @@ func SaveUploadedFile(fileReader io.Reader, fileName string, tempDir string) (*F
 	// Write uploaded content to file
 	written, err := io.Copy(file, fileReader)
 	if err != nil {
+		err = newError(ErrOutputFailed, err, "Failed to write to file: %v", err)
 		return &FileUploadResult{
 			Success:      false,
 			TempDir:      tempDir,
-			ErrorMessage: fmt.Sprintf("Failed to write to file: %v", err),
+			ErrorMessage: err.Error(),
 		}, err
 	}
 
@@ -89,7 +94,7 @@ func CleanTempDirectory(tempDir string) error {
 	// Check if directory name starts with our temporary directory prefix to avoid deleting other directories
 	dirName := filepath.Base(tempDir)
 	if len(dirName) < len(TempDirPrefix) || dirName[:len(TempDirPrefix)] != TempDirPrefix {
-		return fmt.Errorf("Not a valid temporary directory: %s", tempDir)
+		return newError(ErrInvalidTempDir, nil, "Not a valid temporary directory: %s", tempDir)
 	}
 
 	// Remove directory and all its contents
@@ -101,16 +106,19 @@ func ListFilesInTempDir(tempDir string) ([]string, error) {
 	// Check if directory exists
 	info, err := os.Stat(tempDir)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to access temporary directory: %v", err)
+		if os.IsNotExist(err) {
+			return nil, newError(ErrInputNotFound, err, "Failed to access temporary directory: %v", err)
+		}
+		return nil, fmt.Errorf("Failed to access temporary directory: %w", err)
 	}
 	if !info.IsDir() {
-		return nil, fmt.Errorf("%s is not a directory", tempDir)
+		return nil, newError(ErrInputNotDir, nil, "%s is not a directory", tempDir)
 	}
 
 	// Read directory contents
 	files, err := os.ReadDir(tempDir)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read directory contents: %v", err)
+		return nil, fmt.Errorf("Failed to read directory contents: %w", err)
 	}
 
 	// Collect file paths
@@ -123,3 +131,122 @@ func ListFilesInTempDir(tempDir string) ([]string, error) {
 
 	return filePaths, nil
 }
+
+// ZipEntry is a file written to a ZIP archive by WriteZip
+type ZipEntry struct {
+	Name string // Slash-separated path inside the archive
+	Path string // Path of the file on disk
+}
+
+// ZipEntriesForFiles returns the entries of a ZIP archive holding files, each named by its base name
+func ZipEntriesForFiles(files []string) ([]ZipEntry, error) {
+	if len(files) == 0 {
+		return nil, newError(ErrNoInputFiles, nil, "No files to download")
+	}
+
+	entries := make([]ZipEntry, 0, len(files))
+	seen := make(map[string]string, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, newError(ErrInputNotFound, err, "File does not exist: %s", file)
+			}
+			return nil, fmt.Errorf("Cannot access file: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, newError(ErrInvalidOptions, nil, "%s is not a regular file", file)
+		}
+		name := filepath.Base(file)
+		if other, ok := seen[name]; ok {
+			return nil, newError(ErrInvalidOptions, nil, "%s and %s have the same name %s", other, file, name)
+		}
+		seen[name] = file
+		entries = append(entries, ZipEntry{Name: name, Path: file})
+	}
+	return entries, nil
+}
+
+// ZipEntriesForDir returns the entries of a ZIP archive holding the regular files of dir and its
+// subdirectories, each named by its path relative to dir
+func ZipEntriesForDir(dir string) ([]ZipEntry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, newError(ErrInputNotFound, err, "Directory does not exist: %s", dir)
+		}
+		return nil, fmt.Errorf("Cannot access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, newError(ErrInputNotDir, nil, "%s is not a directory", dir)
+	}
+
+	var entries []ZipEntry
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ZipEntry{Name: filepath.ToSlash(rel), Path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read directory contents: %w", err)
+	}
+	return entries, nil
+}
+
+// WriteZip writes a ZIP archive of entries to w, copying one file at a time so that the archive is never
+// held in memory. The files are compressed except for formats that already are, such as PDF and images.
+// It stops with the context error when ctx is cancelled.
+func WriteZip(ctx context.Context, w io.Writer, entries []ZipEntry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := writeZipEntry(zw, entry); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeZipEntry copies the file of entry into zw
+func writeZipEntry(zw *zip.Writer, entry ZipEntry) error {
+	f, err := os.Open(entry.Path)
+	if err != nil {
+		return fmt.Errorf("Cannot open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Cannot get file information: %w", err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = entry.Name
+	header.Method = zip.Deflate
+	switch FileTypeForPath(entry.Path) {
+	case "pdf", "image", archiveFileType:
+		header.Method = zip.Store
+	}
+
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return newError(ErrOutputFailed, err, "Failed to write %s: %v", entry.Name, err)
+	}
+	if _, err := io.Copy(fw, f); err != nil {
+		return newError(ErrOutputFailed, err, "Failed to write %s: %v", entry.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package merger
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestZipEntriesForFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.pdf", "one/b.pdf", "two/b.pdf"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		desc    string
+		files   []string
+		want    []string
+		wantErr error
+	}{
+		{"distinct names", []string{path("one/b.pdf"), path("a.pdf")}, []string{"b.pdf", "a.pdf"}, nil},
+		{"same name in two directories", []string{path("one/b.pdf"), path("a.pdf"), path("two/b.pdf")}, nil, ErrInvalidOptions},
+		{"same file twice", []string{path("a.pdf"), path("a.pdf")}, nil, ErrInvalidOptions},
+		{"directory", []string{path("one")}, nil, ErrInvalidOptions},
+		{"missing file", []string{path("missing.pdf")}, nil, ErrInputNotFound},
+		{"no files", nil, nil, ErrNoInputFiles},
+	}
+	for _, tt := range tests {
+		entries, err := ZipEntriesForFiles(tt.files)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ZipEntriesForFiles() with %s error = %v, want %v", tt.desc, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ZipEntriesForFiles() with %s error = %v", tt.desc, err)
+			continue
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("ZipEntriesForFiles() with %s names = %v, want %v", tt.desc, names, tt.want)
+		}
+	}
+}
+
+func TestZipEntriesForDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.pdf", "sub/b.md", "sub/deeper/c.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names are relative to the directory with forward slashes, empty directories have no entries
+	entries, err := ZipEntriesForDir(dir)
+	if err != nil {
+		t.Fatalf("ZipEntriesForDir() error = %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	if want := []string{"a.pdf", "sub/b.md", "sub/deeper/c.txt"}; !slices.Equal(names, want) {
+		t.Errorf("ZipEntriesForDir() names = %v, want %v", names, want)
+	}
+
+	// The archive holds the content of every file under its name
+	var buf bytes.Buffer
+	if err := WriteZip(context.Background(), &buf, entries); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("WriteZip() wrote an unreadable archive: %v", err)
+	}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(content) != f.Name {
+			t.Errorf("WriteZip() entry %s = %q, %v", f.Name, content, err)
+		}
+	}
+
+	if _, err := ZipEntriesForDir(filepath.Join(dir, "a.pdf")); !errors.Is(err, ErrInputNotDir) {
+		t.Errorf("ZipEntriesForDir() with a file error = %v, want not a directory", err)
+	}
+	if _, err := ZipEntriesForDir(filepath.Join(dir, "missing")); !errors.Is(err, ErrInputNotFound) {
+		t.Errorf("ZipEntriesForDir() with a missing directory error = %v, want not found", err)
+	}
+}
+
+func TestWriteZipMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ZipEntriesForFiles([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A file removed after the entries were collected fails the archive
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteZip(context.Background(), io.Discard, entries); err == nil {
+		t.Errorf("WriteZip() of a removed file succeeded")
+	}
+}
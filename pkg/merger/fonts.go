@@ -0,0 +1,268 @@
+package merger
+
+import (
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// codespaceRange is a range of character codes of one length, from the codespace ranges of a CMap
+type codespaceRange struct {
+	low, high []byte
+}
+
+// textFont decodes the character codes of strings shown with a font into text and glyph widths
+type textFont struct {
+	composite    bool               // Type0 font, with codes of several bytes
+	codespaces   []codespaceRange   // Code lengths of a composite font, two bytes when empty
+	toUnicode    map[uint32]string  // Text of codes, from the ToUnicode map
+	encoding     *[256]rune         // Characters of the codes of a simple font without a ToUnicode map entry
+	widths       map[uint32]float64 // Glyph widths in thousandths of the font size
+	defaultWidth float64
+}
+
+// glyph is a decoded character code
+type glyph struct {
+	text  string
+	width float64
+	space bool // Single-byte code 32, which word spacing applies to
+}
+
+// loadFont reads the font dictionary obj, it returns nil for fonts that cannot be read
+func (e *textExtractor) loadFont(obj types.Object) *textFont {
+	d, err := e.pdf.DereferenceDict(obj)
+	if err != nil || d == nil {
+		return nil
+	}
+
+	f := &textFont{toUnicode: map[uint32]string{}, widths: map[uint32]float64{}}
+	subtype := d.NameEntry("Subtype")
+	if subtype != nil && *subtype == "Type0" {
+		f.composite = true
+		e.loadCIDWidths(f, d)
+	} else {
+		e.loadSimpleFont(f, d, subtype != nil && *subtype == "Type3")
+	}
+
+	if sd, _, err := e.pdf.DereferenceStreamDict(d["ToUnicode"]); err == nil && sd != nil && sd.Decode() == nil {
+		f.parseToUnicode(sd.Content)
+	}
+	return f
+}
+
+// loadSimpleFont reads the widths and encoding of a single-byte font
+func (e *textExtractor) loadSimpleFont(f *textFont, d types.Dict, type3 bool) {
+	// Type 3 glyph widths are in glyph space, usually a thousandth of text space
+	scale := 1.0
+	if m, ok := e.matrixEntry(d, "FontMatrix"); ok && type3 {
+		scale = m[0] * 1000
+	}
+
+	// The standard 14 fonts may have no widths, an average width is assumed for them
+	f.defaultWidth = 500
+	if widths, err := e.pdf.DereferenceArray(d["Widths"]); err == nil && len(widths) > 0 {
+		f.defaultWidth = 0
+		first := 0
+		if n, err := e.pdf.DereferenceInteger(d["FirstChar"]); err == nil && n != nil {
+			first = n.Value()
+		}
+		for i, o := range widths {
+			if w, err := e.pdf.DereferenceNumber(o); err == nil {
+				f.widths[uint32(first+i)] = w * scale
+			}
+		}
+	}
+
+	encoding := winAnsiEncoding
+	o, _ := e.pdf.Dereference(d["Encoding"])
+	switch enc := o.(type) {
+	case types.Name:
+		encoding = encodingByName(string(enc))
+	case types.Dict:
+		if base := enc.NameEntry("BaseEncoding"); base != nil {
+			encoding = encodingByName(*base)
+		}
+		differences, _ := e.pdf.DereferenceArray(enc["Differences"])
+		code := 0
+		for _, o := range differences {
+			switch o := o.(type) {
+			case types.Integer:
+				code = o.Value()
+			case types.Name:
+				if r, ok := glyphRune(string(o)); ok && code >= 0 && code < len(encoding) {
+					encoding[code] = r
+				}
+				code++
+			}
+		}
+	}
+	f.encoding = &encoding
+}
+
+// loadCIDWidths reads the glyph widths of a composite font from its descendant font, assuming
+// character codes are glyph IDs as with the Identity-H encoding used by most PDF producers
+func (e *textExtractor) loadCIDWidths(f *textFont, d types.Dict) {
+	f.defaultWidth = 1000
+	descendants, err := e.pdf.DereferenceArray(d["DescendantFonts"])
+	if err != nil || len(descendants) == 0 {
+		return
+	}
+	cidFont, err := e.pdf.DereferenceDict(descendants[0])
+	if err != nil || cidFont == nil {
+		return
+	}
+	if _, ok := cidFont.Find("DW"); ok {
+		if dw, err := e.pdf.DereferenceNumber(cidFont["DW"]); err == nil {
+			f.defaultWidth = dw
+		}
+	}
+
+	// W lists widths as "first [w1 w2 ...]" or "first last w"
+	w, _ := e.pdf.DereferenceArray(cidFont["W"])
+	for i := 0; i+1 < len(w); {
+		first, err := e.pdf.DereferenceNumber(w[i])
+		if err != nil {
+			return
+		}
+		next, _ := e.pdf.Dereference(w[i+1])
+		if list, ok := next.(types.Array); ok {
+			for j, o := range list {
+				if width, err := e.pdf.DereferenceNumber(o); err == nil {
+					f.widths[uint32(first)+uint32(j)] = width
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, err := e.pdf.DereferenceNumber(w[i+1])
+		if err != nil {
+			return
+		}
+		width, err := e.pdf.DereferenceNumber(w[i+2])
+		if err != nil {
+			return
+		}
+		for c := uint32(first); c <= uint32(last) && c-uint32(first) <= 0xffff; c++ {
+			f.widths[c] = width
+		}
+		i += 3
+	}
+}
+
+// parseToUnicode reads the code lengths and character mappings of a ToUnicode CMap
+func (f *textFont) parseToUnicode(data []byte) {
+	parseContent(data, func(op string, args []any) {
+		switch op {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(args); i += 2 {
+				low, ok1 := args[i].([]byte)
+				high, ok2 := args[i+1].([]byte)
+				if ok1 && ok2 && len(low) > 0 && len(low) == len(high) {
+					f.codespaces = append(f.codespaces, codespaceRange{low: low, high: high})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(args); i += 2 {
+				src, ok1 := args[i].([]byte)
+				dst, ok2 := args[i+1].([]byte)
+				if ok1 && ok2 {
+					f.toUnicode[codeValue(src)] = decodeUTF16(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(args); i += 3 {
+				low, ok1 := args[i].([]byte)
+				high, ok2 := args[i+1].([]byte)
+				if !ok1 || !ok2 {
+					continue
+				}
+				first, last := codeValue(low), codeValue(high)
+				if last < first || last-first > 0xffff {
+					continue
+				}
+				switch dst := args[i+2].(type) {
+				case []byte:
+					// Consecutive codes map to consecutive characters, the last character of dst is incremented
+					for c := first; c <= last; c++ {
+						f.toUnicode[c] = decodeUTF16(incrementLast(dst, c-first))
+					}
+				case []any:
+					for j, o := range dst {
+						if b, ok := o.([]byte); ok && first+uint32(j) <= last {
+							f.toUnicode[first+uint32(j)] = decodeUTF16(b)
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+// decode splits b into character codes and returns their glyphs
+func (f *textFont) decode(b []byte) []glyph {
+	glyphs := make([]glyph, 0, len(b))
+	for len(b) > 0 {
+		n := f.codeLength(b)
+		code := codeValue(b[:n])
+		b = b[n:]
+
+		text, ok := f.toUnicode[code]
+		if !ok && f.encoding != nil && n == 1 {
+			if r := f.encoding[code]; r != 0 {
+				text = string(r)
+			}
+		}
+		width, ok := f.widths[code]
+		if !ok {
+			width = f.defaultWidth
+		}
+		glyphs = append(glyphs, glyph{text: text, width: width, space: n == 1 && code == ' '})
+	}
+	return glyphs
+}
+
+// codeLength returns the length of the character code at the start of b
+func (f *textFont) codeLength(b []byte) int {
+	if !f.composite {
+		return 1
+	}
+	for _, r := range f.codespaces {
+		n := len(r.low)
+		if n > len(b) {
+			continue
+		}
+		in := true
+		for i := 0; i < n && in; i++ {
+			in = b[i] >= r.low[i] && b[i] <= r.high[i]
+		}
+		if in {
+			return n
+		}
+	}
+	return min(2, len(b))
+}
+
+// codeValue returns the big-endian value of a character code
+func codeValue(b []byte) uint32 {
+	var code uint32
+	for _, c := range b {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+// incrementLast returns a copy of the UTF-16BE text b with its last code unit incremented by n
+func incrementLast(b []byte, n uint32) []byte {
+	b = append([]byte(nil), b...)
+	if len(b) < 2 {
+		if len(b) == 1 {
+			b[0] += byte(n)
+		}
+		return b
+	}
+	unit := uint32(b[len(b)-2])<<8 | uint32(b[len(b)-1])
+	unit += n
+	b[len(b)-2], b[len(b)-1] = byte(unit>>8), byte(unit)
+	return b
+}
@@ -0,0 +1,59 @@
+package merger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToUnicodeDecode(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0003> <0020>
+<0004> <D83DDE00>
+endbfchar
+2 beginbfrange
+<0010> <0012> <0041>
+<0020> <0021> [<00660069> <00DF>]
+endbfrange
+endcmap`
+	f := &textFont{composite: true, toUnicode: map[uint32]string{}, widths: map[uint32]float64{0x10: 600}, defaultWidth: 1000}
+	f.parseToUnicode([]byte(cmap))
+
+	var texts []string
+	var widths []float64
+	for _, g := range f.decode([]byte("\x00\x10\x00\x11\x00\x12\x00\x03\x00\x04\x00\x20\x00\x21\x00\x99")) {
+		texts = append(texts, g.text)
+		widths = append(widths, g.width)
+	}
+	if want := []string{"A", "B", "C", " ", "😀", "fi", "ß", ""}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("decode() text = %q, want %q", texts, want)
+	}
+	if want := []float64{600, 1000, 1000, 1000, 1000, 1000, 1000, 1000}; !reflect.DeepEqual(widths, want) {
+		t.Errorf("decode() widths = %v, want %v", widths, want)
+	}
+}
+
+func TestSimpleFontDecode(t *testing.T) {
+	// A code mapped by ToUnicode takes precedence over the encoding
+	encoding := encodingByName("WinAnsiEncoding")
+	encoding[1] = 'ä'
+	f := &textFont{toUnicode: map[uint32]string{'x': "y"}, encoding: &encoding, widths: map[uint32]float64{}}
+
+	var texts []string
+	var spaces []bool
+	for _, g := range f.decode([]byte("a\x01 x\x80")) {
+		texts = append(texts, g.text)
+		spaces = append(spaces, g.space)
+	}
+	if want := []string{"a", "ä", " ", "y", "€"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("decode() text = %q, want %q", texts, want)
+	}
+	if want := []bool{false, false, true, false, false}; !reflect.DeepEqual(spaces, want) {
+		t.Errorf("decode() spaces = %v, want %v", spaces, want)
+	}
+}
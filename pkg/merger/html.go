@@ -0,0 +1,348 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// highlightStyle is the Chroma style used for fenced code blocks
+const highlightStyle = "github"
+
+// tocMaxLevel is the deepest heading level listed in the table of contents
+const tocMaxLevel = 3
+
+// tocEntry is a heading listed in the table of contents of an HTML page
+type tocEntry struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// htmlPage holds the values of htmlTemplate
+type htmlPage struct {
+	Title          string
+	Style          template.CSS
+	HighlightStyle template.CSS
+	TOC            []tocEntry
+	Body           template.HTML
+}
+
+// mergeHTML concatenates the Markdown inputs, renders them as a single HTML page and writes it to w.
+// Headings get IDs and are listed in a sidebar table of contents, fenced code blocks are highlighted with
+// CSS classes and local images are embedded or made relative to the output file as set in opts.HTML.
+func mergeHTML(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	fail := func(err error) (*MergeResult, error) {
+		return &MergeResult{Success: false, ErrorMessage: err.Error()}, err
+	}
+
+	// Progress is reported as the inputs are merged and as the page is written, not for the intermediate document
+	var doc bytes.Buffer
+	markdownOpts := opts
+	markdownOpts.Progress = filterProgress(opts.Progress, ProgressMerged)
+	result, starts, err := concatMarkdown(ctx, inputs, &doc, markdownOpts, true)
+	if err != nil {
+		return result, err
+	}
+
+	md := newMarkdown(false)
+	source := doc.Bytes()
+	root := md.Parser().Parse(text.NewReader(source))
+
+	page := htmlPage{Title: opts.HTML.Title}
+	images := &imageResolver{inputs: inputs, starts: starts, inline: opts.HTML.InlineImages, cache: map[string]string{}}
+	if opts.Output == nil && opts.OutputFile != "" {
+		images.outputDir = filepath.Dir(opts.OutputFile)
+	}
+	err = ast.Walk(root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			title := nodeText(n, source)
+			if page.Title == "" && n.Level == 1 {
+				page.Title = title
+			}
+			if id, ok := n.AttributeString("id"); ok && n.Level <= tocMaxLevel {
+				if b, ok := id.([]byte); ok {
+					page.TOC = append(page.TOC, tocEntry{Level: n.Level, ID: string(b), Text: title})
+				}
+			}
+		case *ast.Image:
+			images.resolve(n)
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if page.Title == "" {
+		page.Title = "Document"
+		if opts.OutputFile != "" {
+			page.Title = strings.TrimSuffix(filepath.Base(opts.OutputFile), filepath.Ext(opts.OutputFile))
+		}
+	}
+
+	var body bytes.Buffer
+	if err := md.Renderer().Render(&body, source, root); err != nil {
+		return fail(fmt.Errorf("Failed to render HTML: %w", err))
+	}
+	page.Body = template.HTML(body.String())
+	page.Style = template.CSS(htmlStyle)
+
+	highlight, err := highlightCSS()
+	if err != nil {
+		return fail(fmt.Errorf("Failed to render HTML: %w", err))
+	}
+	page.HighlightStyle = template.CSS(highlight)
+
+	out := &progressWriter{w: w, progress: opts.Progress}
+	if err := htmlTemplate.Execute(out, page); err != nil {
+		if !errors.Is(err, ErrOutputFailed) {
+			err = newError(ErrOutputFailed, err, "Cannot write output: %v", err)
+		}
+		return fail(err)
+	}
+
+	result.Warnings = append(result.Warnings, images.warnings...)
+	return result, nil
+}
+
+// newMarkdown returns the Markdown converter used for HTML and EPUB output: GitHub-flavored Markdown with heading IDs
+// and code blocks highlighted with CSS classes. Raw HTML is kept in HTML pages, as GitHub does for most tags, and
+// left out of EPUB chapters, which must be valid XHTML.
+func newMarkdown(xhtml bool) goldmark.Markdown {
+	rendererOptions := []renderer.Option{goldmarkhtml.WithUnsafe()}
+	if xhtml {
+		rendererOptions = []renderer.Option{goldmarkhtml.WithXHTML()}
+	}
+	return goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithStyle(highlightStyle),
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+			),
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		goldmark.WithRendererOptions(rendererOptions...),
+	)
+}
+
+// highlightCSS returns the stylesheet of the highlighted code blocks
+func highlightCSS() (string, error) {
+	var css bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&css, styles.Get(highlightStyle)); err != nil {
+		return "", err
+	}
+	return css.String(), nil
+}
+
+// localImagePath returns the file a Markdown image destination refers to, relative to the Markdown file at
+// source. ok is false for URLs, absolute paths and fragments.
+func localImagePath(dest, source string) (path string, u *url.URL, ok bool) {
+	u, err := url.Parse(dest)
+	if err != nil || dest == "" || u.Scheme != "" || u.Host != "" || strings.HasPrefix(dest, "/") || strings.HasPrefix(dest, "#") {
+		return "", nil, false
+	}
+	return filepath.Join(filepath.Dir(source), filepath.FromSlash(u.Path)), u, true
+}
+
+// nodeText returns the plain text of the inline content of n
+func nodeText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Segment.Value(source))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(c.Value)
+		default:
+			sb.WriteString(nodeText(c, source))
+		}
+	}
+	return sb.String()
+}
+
+// imageResolver rewrites the destinations of local images of a merged Markdown document, which are relative
+// to the file each image comes from
+type imageResolver struct {
+	inputs    []Input
+	starts    []int64 // Offset of the content of each input in the merged document
+	inline    bool
+	outputDir string            // Directory relative paths are rewritten against, kept as they are when empty
+	cache     map[string]string // Data URIs of inlined files, by path
+	warnings  []Warning
+}
+
+// resolve rewrites the destination of img, embedding the image as a data URI when inline is set
+func (r *imageResolver) resolve(img *ast.Image) {
+	in := r.source(img)
+	if in == nil || in.Reader != nil {
+		return
+	}
+	path, u, ok := localImagePath(string(img.Destination), in.Path)
+	if !ok {
+		return
+	}
+
+	if r.inline {
+		uri, ok := r.cache[path]
+		if !ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				r.warnings = append(r.warnings, Warning{Path: in.displayName(), Message: fmt.Sprintf("Image not embedded: %v", err)})
+				r.cache[path] = ""
+				return
+			}
+			mediaType := mime.TypeByExtension(filepath.Ext(path))
+			if mediaType == "" {
+				mediaType = http.DetectContentType(data)
+			}
+			uri = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+			r.cache[path] = uri
+		}
+		if uri != "" {
+			img.Destination = []byte(uri)
+		}
+		return
+	}
+
+	if r.outputDir == "" {
+		return
+	}
+	absPath, err1 := filepath.Abs(path)
+	absOutput, err2 := filepath.Abs(r.outputDir)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	if rel, err := filepath.Rel(absOutput, absPath); err == nil {
+		u.Path = filepath.ToSlash(rel)
+		img.Destination = []byte(u.String())
+	}
+}
+
+// source returns the input the image node comes from, found by the position of its text in the merged document
+func (r *imageResolver) source(n ast.Node) *Input {
+	offset := -1
+	for p := ast.Node(n); p != nil && offset < 0; p = p.Parent() {
+		if p.Type() == ast.TypeBlock && p.Lines().Len() > 0 {
+			offset = p.Lines().At(0).Start
+		}
+	}
+	if offset < 0 {
+		return nil
+	}
+	i := sort.Search(len(r.starts), func(i int) bool { return r.starts[i] > int64(offset) }) - 1
+	if i < 0 {
+		return nil
+	}
+	return &r.inputs[i]
+}
+
+// filterProgress returns a ProgressFunc passing on only the events of the given stage
+func filterProgress(progress ProgressFunc, stage ProgressStage) ProgressFunc {
+	if progress == nil {
+		return nil
+	}
+	return func(event ProgressEvent) {
+		if event.Stage == stage {
+			progress(event)
+		}
+	}
+}
+
+var htmlTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+{{.Style}}
+{{.HighlightStyle}}
+</style>
+</head>
+<body>
+{{- if .TOC}}
+<nav class="toc">
+<div class="toc-title">Contents</div>
+<ul>
+{{- range .TOC}}
+<li class="toc-h{{.Level}}"><a href="#{{.ID}}">{{.Text}}</a></li>
+{{- end}}
+</ul>
+</nav>
+{{- end}}
+<main class="content">
+{{.Body}}
+</main>
+</body>
+</html>
+`))
+
+// htmlStyle is the stylesheet embedded in HTML pages, close to GitHub's rendering of Markdown
+const htmlStyle = `*, *::before, *::after { box-sizing: border-box; }
+body { margin: 0; color: #1f2328; background: #fff; font: 16px/1.6 -apple-system, BlinkMacSystemFont, "Segoe UI", "Noto Sans", Helvetica, Arial, sans-serif; }
+.toc { position: fixed; top: 0; bottom: 0; left: 0; width: 280px; overflow-y: auto; padding: 24px 16px; border-right: 1px solid #d1d9e0; background: #f6f8fa; font-size: 14px; }
+.toc-title { font-weight: 600; margin-bottom: 8px; }
+.toc ul { list-style: none; margin: 0; padding: 0; }
+.toc li { margin: 4px 0; }
+.toc .toc-h2 { padding-left: 12px; }
+.toc .toc-h3 { padding-left: 24px; }
+.toc a { color: #1f2328; text-decoration: none; }
+.toc a:hover { color: #0969da; text-decoration: underline; }
+.content { max-width: 980px; margin: 0 auto; padding: 32px 48px; }
+.toc + .content { margin-left: 280px; }
+h1, h2, h3, h4, h5, h6 { margin: 24px 0 16px; font-weight: 600; line-height: 1.25; }
+h1 { font-size: 2em; padding-bottom: .3em; border-bottom: 1px solid #d1d9e0; }
+h2 { font-size: 1.5em; padding-bottom: .3em; border-bottom: 1px solid #d1d9e0; }
+h3 { font-size: 1.25em; }
+p, blockquote, ul, ol, dl, table, pre { margin: 0 0 16px; }
+a { color: #0969da; }
+img { max-width: 100%; }
+hr { height: 4px; margin: 24px 0; border: 0; background: #d1d9e0; }
+blockquote { padding: 0 1em; color: #59636e; border-left: 4px solid #d1d9e0; }
+code { padding: .2em .4em; font: 85% ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; background: rgba(129, 139, 152, .12); border-radius: 6px; }
+.content pre { padding: 16px; overflow: auto; font-size: 85%; line-height: 1.45; background: #f6f8fa; border-radius: 6px; }
+pre code { padding: 0; font-size: 100%; background: transparent; }
+table { border-collapse: collapse; display: block; overflow: auto; }
+th, td { padding: 6px 13px; border: 1px solid #d1d9e0; }
+tr:nth-child(2n) { background: #f6f8fa; }
+li > input[type=checkbox] { margin-right: .5em; }
+@media (max-width: 900px) {
+  .toc { position: static; width: auto; border-right: 0; border-bottom: 1px solid #d1d9e0; }
+  .toc + .content { margin-left: auto; }
+  .content { padding: 24px 16px; }
+}
+@media print {
+  .toc { display: none; }
+  .toc + .content { margin-left: auto; }
+}`
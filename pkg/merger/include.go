@@ -0,0 +1,295 @@
+package merger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultIncludeDepth is the number of nested includes resolved when MarkdownOptions.IncludeDepth is 0
+const defaultIncludeDepth = 8
+
+// includeDirective matches a line that consists of an include directive, either an HTML comment
+// (<!-- include: path -->) or a shortcode ({{< include path >}}), the path may be quoted
+var includeDirective = regexp.MustCompile(`^\s*(?:<!--\s*include:?\s+(.+?)\s*-->|\{\{<\s*include\s+(.+?)\s*>\}\})\s*$`)
+
+// includeResolver replaces include directives in Markdown sources with the content of the files they name
+type includeResolver struct {
+	maxDepth int
+	encoding string        // Encoding of the included files, detected when empty
+	archived *archivedFile // Archive the input was read from, its includes name other files of the archive
+	stream   bool          // Input read from a stream, which has no location to resolve includes against
+	stack    []string      // Paths of the files being expanded, outermost first: absolute, or inside the archive
+	included []string      // Paths of the files included so far
+}
+
+// readMarkdown returns the content of a Markdown input converted to UTF-8, with its include directives resolved
+// and its line endings normalized as set in opts.Markdown
+func readMarkdown(in Input, opts Options) ([]byte, error) {
+	content, err := in.readAll()
+	if err != nil {
+		return nil, err
+	}
+	content, enc, err := decodeText(content, opts.Markdown.Encoding)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOptions) {
+			return nil, err
+		}
+		return nil, &InvalidFileError{Path: in.displayName(), Reason: fmt.Sprintf("cannot decode as %s: %v", enc, err)}
+	}
+	if enc != "utf-8" {
+		opts.Logger.Info("Input converted to UTF-8", "path", in.displayName(), "encoding", enc)
+	}
+
+	if opts.Markdown.ResolveIncludes {
+		if content, err = resolveIncludes(in, content, opts.Markdown); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Markdown.LineEndings != "" {
+		content = normalizeLineEndings(content)
+	}
+	return content, nil
+}
+
+// resolveIncludes replaces the include directives of content, read from in, with the files they name
+func resolveIncludes(in Input, content []byte, opts MarkdownOptions) ([]byte, error) {
+	return newIncludeResolver(in, opts).expand(content, in.displayName(), 1)
+}
+
+// newIncludeResolver returns a resolver for the includes of in. Files of an archive include other files of the
+// archive, relative to their own directory. Other streams, such as stdin, have no location and cannot include.
+func newIncludeResolver(in Input, opts MarkdownOptions) *includeResolver {
+	r := &includeResolver{maxDepth: opts.IncludeDepth, encoding: opts.Encoding, archived: in.archived}
+	if r.maxDepth <= 0 {
+		r.maxDepth = defaultIncludeDepth
+	}
+	switch {
+	case in.archived != nil:
+		r.stack = []string{in.archived.name}
+	case in.Reader != nil || in.Path == "":
+		r.stream = true
+		r.stack = []string{in.displayName()}
+	default:
+		path := in.Path
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		r.stack = []string{path}
+	}
+	return r
+}
+
+// resolve returns the path of the file named target by a directive of the file on top of the stack
+func (r *includeResolver) resolve(target string) string {
+	current := r.stack[len(r.stack)-1]
+	if r.archived != nil {
+		return path.Join(path.Dir(current), target)
+	}
+	resolved := filepath.Join(filepath.Dir(current), filepath.FromSlash(target))
+	if abs, err := filepath.Abs(resolved); err == nil {
+		resolved = abs
+	}
+	return resolved
+}
+
+// read returns the content of the included file at resolved
+func (r *includeResolver) read(resolved string) ([]byte, error) {
+	if r.archived == nil {
+		return os.ReadFile(resolved)
+	}
+	content, ok := r.archived.files[resolved]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return content, nil
+}
+
+// dropIncludedInputs leaves out the inputs that other inputs include, unless that would leave none, as with
+// an include cycle whose error is reported by the merge. Inputs that cannot be read are kept for the same reason.
+func dropIncludedInputs(inputs []Input, opts Options) []Input {
+	included := make(map[string]bool)
+	for _, in := range inputs {
+		content, err := in.readAll()
+		if err != nil {
+			continue
+		}
+		if content, _, err = decodeText(content, opts.Markdown.Encoding); err != nil {
+			continue
+		}
+		r := newIncludeResolver(in, opts.Markdown)
+		r.expand(content, in.displayName(), 1)
+		for _, p := range r.included {
+			included[r.key(p)] = true
+		}
+	}
+	if len(included) == 0 {
+		return inputs
+	}
+
+	kept := make([]Input, 0, len(inputs))
+	for _, in := range inputs {
+		if included[inputKey(in)] {
+			opts.Logger.Info("Input file left out, it is included by another input", "path", in.displayName())
+			continue
+		}
+		kept = append(kept, in)
+	}
+	if len(kept) == 0 {
+		return inputs
+	}
+	return kept
+}
+
+// key returns the name of an included file as inputKey returns it for inputs
+func (r *includeResolver) key(resolved string) string {
+	if r.archived != nil {
+		return r.archived.archive + "/" + resolved
+	}
+	return resolved
+}
+
+// inputKey returns an absolute path for a file input, the archive and path inside it for an archived input
+func inputKey(in Input) string {
+	if in.archived != nil {
+		return in.archived.archive + "/" + in.archived.name
+	}
+	if abs, err := filepath.Abs(in.Path); err == nil {
+		return abs
+	}
+	return in.Path
+}
+
+// expand resolves the include directives of content, read from the file on top of the stack starting at line
+// firstLine. Directives inside fenced code blocks are left alone. The headings of an included file are shifted
+// so that its top level sits one level below the heading preceding the directive.
+func (r *includeResolver) expand(content []byte, name string, firstLine int) ([]byte, error) {
+	if !bytes.Contains(content, []byte("include")) {
+		return content, nil
+	}
+	var out bytes.Buffer
+	inFence := false
+	level := 0 // Level of the last heading seen outside included content
+	for i, line := range bytes.SplitAfter(content, []byte("\n")) {
+		text := strings.TrimRight(string(line), "\r\n")
+		if isFenceLine(text) {
+			inFence = !inFence
+		}
+		if inFence {
+			out.Write(line)
+			continue
+		}
+		m := includeDirective.FindStringSubmatch(text)
+		if m == nil {
+			if l, _ := parseATXHeading(text); l > 0 {
+				level = l
+			}
+			out.Write(line)
+			continue
+		}
+
+		target := strings.Trim(m[1]+m[2], `"'`)
+		included, err := r.include(target, name, firstLine+i)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(shiftHeadings(included, level))
+		if len(included) > 0 && !bytes.HasSuffix(included, []byte("\n")) {
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// include reads the file named target by the directive on line lineNo of the file called name, and resolves
+// its own includes. Its front matter is left out.
+func (r *includeResolver) include(target, name string, lineNo int) ([]byte, error) {
+	fail := func(format string, args ...any) error {
+		return &InvalidFileError{Path: name, Reason: fmt.Sprintf("line %d: ", lineNo) + fmt.Sprintf(format, args...)}
+	}
+	if r.stream {
+		return nil, fail("cannot include %s, includes are only resolved in files and archives, not in streams", target)
+	}
+	path := r.resolve(target)
+	for i, p := range r.stack {
+		if p == path {
+			chain := make([]string, 0, len(r.stack)-i+1)
+			for _, p := range r.stack[i:] {
+				chain = append(chain, filepath.Base(p))
+			}
+			return nil, fail("include cycle %s -> %s", strings.Join(chain, " -> "), filepath.Base(path))
+		}
+	}
+	if len(r.stack) > r.maxDepth {
+		return nil, fail("cannot include %s, the include depth limit of %d is reached", target, r.maxDepth)
+	}
+
+	content, err := r.read(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fail("cannot include %s, file not found", target)
+		}
+		return nil, fail("cannot include %s: %v", target, err)
+	}
+	content, enc, err := decodeText(content, r.encoding)
+	if err != nil {
+		return nil, fail("cannot include %s, cannot decode as %s: %v", target, enc, err)
+	}
+	firstLine := 1
+	if _, body, err := splitFrontMatter(content); err == nil {
+		firstLine += bytes.Count(content[:len(content)-len(body)], []byte("\n"))
+		content = body
+	}
+
+	r.included = append(r.included, path)
+	r.stack = append(r.stack, path)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+	return r.expand(content, target, firstLine)
+}
+
+// shiftHeadings moves the ATX headings of content down so that the highest of them is at level parent+1
+func shiftHeadings(content []byte, parent int) []byte {
+	top := 0
+	for _, h := range parseHeadings(content) {
+		if top == 0 || h.Level < top {
+			top = h.Level
+		}
+	}
+	if top == 0 {
+		return content
+	}
+	return addHeadingLevels(content, parent+1-top)
+}
+
+// addHeadingLevels moves the ATX headings of content down by shift levels, levels beyond 6 are kept at 6
+func addHeadingLevels(content []byte, shift int) []byte {
+	if shift <= 0 {
+		return content
+	}
+
+	var out bytes.Buffer
+	inFence := false
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		text := string(line)
+		if isFenceLine(strings.TrimRight(text, "\r\n")) {
+			inFence = !inFence
+		}
+		if !inFence {
+			if l, _ := parseATXHeading(strings.TrimRight(text, "\r\n")); l > 0 {
+				trimmed := strings.TrimLeft(text, " ")
+				out.WriteString(text[:len(text)-len(trimmed)])
+				out.WriteString(strings.Repeat("#", min(l+shift, 6)))
+				out.WriteString(trimmed[l:])
+				continue
+			}
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
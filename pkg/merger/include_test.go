@@ -0,0 +1,108 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// mergeMarkdownForTest merges Markdown inputs with includes resolved and returns the merged document
+func mergeMarkdownForTest(t *testing.T, opts Options) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	opts.Format = FormatMarkdown
+	opts.Output = &out
+	opts.Markdown.ResolveIncludes = true
+	_, err := MergeContext(context.Background(), opts)
+	return out.String(), err
+}
+
+func TestIncludedFilesMergedOnce(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"chapter.md":        "# Chapter\n\n<!-- include: snippets/legal.md -->\n",
+		"snippets/legal.md": "LEGAL NOTICE\n",
+		"other.md":          "# Other\n",
+		"SUMMARY.md":        "# Summary\n\n- [Chapter](chapter.md)\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merged, err := mergeMarkdownForTest(t, Options{InputDir: dir})
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if n := strings.Count(merged, "LEGAL NOTICE"); n != 1 {
+		t.Errorf("Merge() wrote the included snippet %d times, want 1:\n%s", n, merged)
+	}
+	if strings.Contains(merged, "[Chapter](chapter.md)") {
+		t.Errorf("Merge() merged SUMMARY.md as a chapter:\n%s", merged)
+	}
+	if !strings.Contains(merged, "# Other") {
+		t.Errorf("Merge() left out a file that nothing includes:\n%s", merged)
+	}
+}
+
+func TestArchiveIncludesResolvedInArchive(t *testing.T) {
+	// A file with the included name in the working directory must not be picked up
+	wd := t.TempDir()
+	t.Chdir(wd)
+	if err := os.MkdirAll("parts", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("parts", "intro.md"), []byte("FROM WORKING DIRECTORY\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file := writeTestZip(t, map[string][]byte{
+		"docs/book.md":        []byte("# Book\n\n<!-- include: parts/intro.md -->\n\n{{< include ../shared/footer.txt >}}\n"),
+		"docs/parts/intro.md": []byte("FROM ARCHIVE\n"),
+		"shared/footer.txt":   []byte("FOOTER\n"),
+	})
+	merged, err := mergeMarkdownForTest(t, Options{InputDir: file})
+	if err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	if strings.Contains(merged, "FROM WORKING DIRECTORY") {
+		t.Errorf("Merge() resolved an include against the working directory:\n%s", merged)
+	}
+	if n := strings.Count(merged, "FROM ARCHIVE"); n != 1 {
+		t.Errorf("Merge() wrote the included archive entry %d times, want 1:\n%s", n, merged)
+	}
+	if !strings.Contains(merged, "FOOTER") {
+		t.Errorf("Merge() did not include a text file of the archive:\n%s", merged)
+	}
+}
+
+func TestStreamIncludesRejected(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("snippet.md", []byte("SNIPPET\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := mergeMarkdownForTest(t, Options{Inputs: []Input{
+		{Name: "stdin", Reader: strings.NewReader("# Piped\n\n<!-- include: snippet.md -->\n")},
+	}})
+	if !errors.Is(err, ErrInvalidFile) {
+		t.Errorf("Merge() of a stream with an include error = %v, want an invalid file", err)
+	}
+
+	// Streams without includes are merged as before
+	merged, err := mergeMarkdownForTest(t, Options{Inputs: []Input{
+		{Name: "stdin", Reader: strings.NewReader("# Piped\n")},
+	}})
+	if err != nil || !strings.Contains(merged, "# Piped") {
+		t.Errorf("Merge() of a stream = %q, %v", merged, err)
+	}
+}
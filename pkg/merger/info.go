@@ -0,0 +1,266 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// PageSize stores the dimensions of a PDF page in points
+type PageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// PDFDetails stores information read from the content of a PDF file
+type PDFDetails struct {
+	PageCount int        `json:"pageCount"`
+	PageSizes []PageSize `json:"pageSizes,omitempty"` // Distinct page sizes in order of first appearance
+	Version   string     `json:"version"`
+	Encrypted bool       `json:"encrypted"`
+	Title     string     `json:"title,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Bookmarks int        `json:"bookmarks"`
+}
+
+// MarkdownDetails stores information read from the content of a Markdown file
+type MarkdownDetails struct {
+	WordCount   int                    `json:"wordCount"`
+	Headings    []MarkdownHeading      `json:"headings,omitempty"`
+	FrontMatter map[string]interface{} `json:"frontMatter,omitempty"`
+}
+
+// FileDetails stores detailed information about a PDF or Markdown file
+type FileDetails struct {
+	Path     string           `json:"path"`
+	Title    string           `json:"title"`
+	Type     string           `json:"type"` // File type: pdf or markdown
+	Size     int64            `json:"size"`
+	ModTime  time.Time        `json:"modTime"`
+	PDF      *PDFDetails      `json:"pdf,omitempty"`
+	Markdown *MarkdownDetails `json:"markdown,omitempty"`
+	Error    string           `json:"error,omitempty"` // Set when the file content could not be read
+}
+
+// fileTypeForPath returns the registered file type (e.g. pdf or markdown) based on the file extension, or an empty string
+func fileTypeForPath(path string) string {
+	return FileTypeForPath(path)
+}
+
+// GetFileDetails reads detailed information about a single PDF or Markdown file
+func GetFileDetails(path string) (*FileDetails, error) {
+	return GetFileDetailsContext(context.Background(), path)
+}
+
+// GetFileDetailsContext reads detailed information about a single PDF or Markdown file, stopping when ctx is cancelled
+func GetFileDetailsContext(ctx context.Context, path string) (*FileDetails, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, newError(ErrInputNotFound, err, "File does not exist: %s", path)
+		}
+		return nil, fmt.Errorf("Cannot access file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil, newError(ErrUnsupportedFileType, nil, "%s is a directory, not a file", path)
+	}
+
+	details := &FileDetails{
+		Path:    path,
+		Title:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Type:    fileTypeForPath(path),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+
+	switch details.Type {
+	case "pdf":
+		details.PDF, err = readPDFDetails(ctx, path)
+	case "markdown":
+		details.Markdown, err = readMarkdownDetails(path)
+	default:
+		return nil, newError(ErrUnsupportedFileType, nil, "%s is not a PDF or Markdown file", path)
+	}
+	if err != nil {
+		return details, err
+	}
+
+	return details, nil
+}
+
+// readPDFDetails reads page, version, metadata and bookmark information from a PDF file
+func readPDFDetails(ctx context.Context, path string) (*PDFDetails, error) {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.LISTINFO
+	pdfCtx, err := readPDFInput(ctx, Input{Path: path}, conf)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		// Without the user password only the header can be read
+		if errors.Is(err, pdfcpu.ErrWrongPassword) {
+			return &PDFDetails{Version: headerVersion(path), Encrypted: true}, nil
+		}
+		return nil, &InvalidFileError{Path: path, Reason: cleanPDFError(err)}
+	}
+
+	details := &PDFDetails{
+		PageCount: pdfCtx.PageCount,
+		Version:   pdfCtx.VersionString(),
+		Encrypted: pdfCtx.Encrypt != nil,
+		Title:     pdfCtx.Title,
+		Author:    pdfCtx.Author,
+	}
+
+	// Collect distinct page sizes, keeping the order of first appearance
+	dims, err := pdfCtx.PageDims()
+	if err != nil {
+		return nil, &InvalidFileError{Path: path, Reason: "cannot read page sizes: " + cleanPDFError(err)}
+	}
+	seen := make(map[PageSize]bool)
+	for _, d := range dims {
+		size := PageSize{Width: d.Width, Height: d.Height}
+		if !seen[size] {
+			seen[size] = true
+			details.PageSizes = append(details.PageSizes, size)
+		}
+	}
+
+	bookmarks, err := pdfcpu.Bookmarks(pdfCtx)
+	if err != nil {
+		return nil, &InvalidFileError{Path: path, Reason: "cannot read bookmarks: " + cleanPDFError(err)}
+	}
+	details.Bookmarks = countBookmarks(bookmarks)
+
+	return details, nil
+}
+
+// headerVersion returns the version in the header of the PDF file at path, or an empty string if it cannot be read
+func headerVersion(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	head := make([]byte, 1024)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	i := bytes.Index(head, []byte("%PDF-"))
+	if i < 0 || len(head) < i+8 {
+		return ""
+	}
+	return string(head[i+5 : i+8])
+}
+
+// countBookmarks counts bookmarks including all nested children
+func countBookmarks(bookmarks []pdfcpu.Bookmark) int {
+	count := len(bookmarks)
+	for _, bm := range bookmarks {
+		count += countBookmarks(bm.Kids)
+	}
+	return count
+}
+
+// readMarkdownDetails reads word count, heading outline and front matter from a Markdown file
+func readMarkdownDetails(path string) (*MarkdownDetails, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	frontMatter, body, err := splitFrontMatter(content)
+	if err != nil {
+		return nil, &InvalidFileError{Path: path, Reason: err.Error()}
+	}
+
+	return &MarkdownDetails{
+		WordCount:   countWords(body),
+		Headings:    parseHeadings(body),
+		FrontMatter: frontMatter,
+	}, nil
+}
+
+// GetPDFFilesDetails gets detailed information about all PDF files in the specified directory
+func GetPDFFilesDetails(inputDir string) ([]FileDetails, error) {
+	return getFilesDetails(context.Background(), inputDir, "pdf")
+}
+
+// GetPDFFilesDetailsContext is like GetPDFFilesDetails but stops when ctx is cancelled
+func GetPDFFilesDetailsContext(ctx context.Context, inputDir string) ([]FileDetails, error) {
+	return getFilesDetails(ctx, inputDir, "pdf")
+}
+
+// GetMarkdownFilesDetails gets detailed information about all Markdown files in the specified directory
+func GetMarkdownFilesDetails(inputDir string) ([]FileDetails, error) {
+	return getFilesDetails(context.Background(), inputDir, "markdown")
+}
+
+// GetMarkdownFilesDetailsContext is like GetMarkdownFilesDetails but stops when ctx is cancelled
+func GetMarkdownFilesDetailsContext(ctx context.Context, inputDir string) ([]FileDetails, error) {
+	return getFilesDetails(ctx, inputDir, "markdown")
+}
+
+// getFilesDetails collects details for all files of the given type in inputDir.
+// Files whose content cannot be read are still listed, with the reason in the Error field.
+func getFilesDetails(ctx context.Context, inputDir string, fileType string) ([]FileDetails, error) {
+	// Check if input directory exists
+	if err := checkInputDir(inputDir); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !info.IsDir() && fileTypeForPath(path) == fileType {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error scanning directory: %w", err)
+	}
+
+	// Sort files in alphanumeric order
+	sort.Strings(paths)
+
+	detailsList := make([]FileDetails, 0, len(paths))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		details, err := GetFileDetailsContext(ctx, path)
+		if details == nil {
+			details = &FileDetails{
+				Path:  path,
+				Title: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+				Type:  fileType,
+			}
+		}
+		if err != nil {
+			details.Error = err.Error()
+		}
+		detailsList = append(detailsList, *details)
+	}
+
+	return detailsList, nil
+}
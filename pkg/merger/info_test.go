@@ -0,0 +1,43 @@
+package merger
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+func TestGetFileDetailsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		desc     string
+		userPW   string
+		wantPage int
+	}{
+		{"owner password only", "", 1},
+		{"user password", "secret", 0}, // Only the header can be read
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.desc+".pdf")
+		conf := model.NewAESConfiguration(tt.userPW, "owner", 256)
+		if err := api.EncryptFile(filepath.Join("testdata", "small.pdf"), path, conf); err != nil {
+			t.Fatal(err)
+		}
+
+		details, err := GetFileDetails(path)
+		if err != nil {
+			t.Errorf("GetFileDetails() with %s error = %v", tt.desc, err)
+			continue
+		}
+		if !details.PDF.Encrypted {
+			t.Errorf("GetFileDetails() with %s not encrypted", tt.desc)
+		}
+		if details.PDF.Version != "1.7" {
+			t.Errorf("GetFileDetails() with %s version = %q, want 1.7", tt.desc, details.PDF.Version)
+		}
+		if details.PDF.PageCount != tt.wantPage {
+			t.Errorf("GetFileDetails() with %s page count = %d, want %d", tt.desc, details.PDF.PageCount, tt.wantPage)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package merger
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Input is a document to merge, read from a file or from a stream
+type Input struct {
+	Name   string        // Name used in results, messages, bookmarks and Markdown titles, defaults to Path
+	Path   string        // File to read when Reader is nil
+	Reader io.ReadSeeker // Content of the document, read from the start
+	Title  string        // Title of the Markdown section or chapter, defaults to the name without directory and extension
+	Level  int           // Nesting depth of a Markdown section, moving its title and headings down by Level-1
+
+	fileType string        // Type detected from the content of a listed file, the type of its name when empty
+	archived *archivedFile // Location of an input read from an archive
+}
+
+// displayName returns the name used for the input in results and messages
+func (in Input) displayName() string {
+	if in.Name != "" {
+		return in.Name
+	}
+	return in.Path
+}
+
+// title returns the title of the input, by default its name without directory and extension
+func (in Input) title() string {
+	if in.Title != "" {
+		return in.Title
+	}
+	name := filepath.Base(in.displayName())
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// headingLevel returns the level of the title of a Markdown section, 1 unless the input is nested
+func (in Input) headingLevel() int {
+	return min(max(in.Level, 1), 6)
+}
+
+// open returns a reader positioned at the start of the input, and a function releasing it
+func (in Input) open() (io.ReadSeeker, func(), error) {
+	if in.Reader != nil {
+		if _, err := in.Reader.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		return in.Reader, func() {}, nil
+	}
+
+	f, err := os.Open(in.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// readAll returns the whole content of the input
+func (in Input) readAll() ([]byte, error) {
+	rs, release, err := in.open()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return io.ReadAll(rs)
+}
+
+// fileInputs returns an Input for each path
+func fileInputs(paths []string) []Input {
+	inputs := make([]Input, len(paths))
+	for i, path := range paths {
+		inputs[i] = Input{Path: path}
+	}
+	return inputs
+}
+
+// inputNames returns the display name of each input
+func inputNames(inputs []Input) []string {
+	names := make([]string, len(inputs))
+	for i, in := range inputs {
+		names[i] = in.displayName()
+	}
+	return names
+}
+
+// MergeStreams merges inputs in the given order and writes the result to w.
+// opts.InputDir, opts.Files, opts.FileInputs, opts.Inputs and opts.OutputFile are ignored; opts.Format must be set
+// unless it can be inferred from the extension of the first input name.
+func MergeStreams(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	opts.InputDir = ""
+	opts.Files = nil
+	opts.FileInputs = nil
+	opts.Inputs = inputs
+	opts.OutputFile = ""
+	opts.Output = w
+	if opts.Format == "" && len(inputs) > 0 {
+		opts.Format = FormatForFileType(fileTypeForPath(inputs[0].displayName()))
+	}
+	return MergeContext(ctx, opts)
+}
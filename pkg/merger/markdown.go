@@ -0,0 +1,148 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// MarkdownHeading stores a heading found in a Markdown document
+type MarkdownHeading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+}
+
+// splitFrontMatter separates a leading YAML front matter block (delimited by "---" lines) from the document body.
+// If the document has no front matter, fm is nil and body is the whole content.
+func splitFrontMatter(content []byte) (fm map[string]interface{}, body []byte, err error) {
+	firstEnd := bytes.IndexByte(content, '\n')
+	if firstEnd < 0 || strings.TrimRight(string(content[:firstEnd]), "\r") != "---" {
+		return nil, content, nil
+	}
+
+	// Find the closing delimiter line
+	start := firstEnd + 1
+	for pos := start; pos < len(content); {
+		line, next := content[pos:], len(content)
+		if end := bytes.IndexByte(line, '\n'); end >= 0 {
+			line, next = line[:end], pos+end+1
+		}
+
+		delim := strings.TrimRight(string(line), "\r")
+		if delim == "---" || delim == "..." {
+			fm = make(map[string]interface{})
+			if err := yaml.Unmarshal(content[start:pos], &fm); err != nil {
+				return nil, content, fmt.Errorf("Invalid front matter: %v", err)
+			}
+			return normalizeYAMLMap(fm), content[next:], nil
+		}
+		pos = next
+	}
+
+	// No closing delimiter, treat the whole file as body
+	return nil, content, nil
+}
+
+// normalizeYAMLMap converts the map[interface{}]interface{} values produced by yaml.v2 into
+// map[string]interface{} so front matter can be encoded as JSON
+func normalizeYAMLMap(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		m[k] = normalizeYAMLValue(v)
+	}
+	return m
+}
+
+func normalizeYAMLValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = normalizeYAMLValue(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalizeYAMLValue(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// isFenceLine reports whether line opens or closes a fenced code block
+func isFenceLine(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// parseATXHeading parses an ATX heading line ("## Title"), returning level 0 if line is not a heading
+func parseATXHeading(line string) (level int, text string) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, ""
+	}
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, ""
+	}
+	if level < len(trimmed) && trimmed[level] != ' ' && trimmed[level] != '\t' {
+		return 0, ""
+	}
+
+	text = strings.TrimSpace(trimmed[level:])
+	// Remove optional closing sequence, which must be preceded by a space
+	if closed := strings.TrimRight(text, "#"); closed == "" || strings.HasSuffix(closed, " ") || strings.HasSuffix(closed, "\t") {
+		text = strings.TrimSpace(closed)
+	}
+	return level, text
+}
+
+// parseHeadings returns all ATX headings in a Markdown document, ignoring fenced code blocks
+func parseHeadings(body []byte) []MarkdownHeading {
+	var headings []MarkdownHeading
+	inFence := false
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if isFenceLine(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if level, text := parseATXHeading(line); level > 0 {
+			headings = append(headings, MarkdownHeading{Level: level, Text: text})
+		}
+	}
+	return headings
+}
+
+// countWords counts words in text, counting each CJK character as one word
+func countWords(text []byte) int {
+	count := 0
+	for _, field := range strings.Fields(string(text)) {
+		hasWord := false
+		for _, r := range field {
+			switch {
+			case isCJK(r):
+				count++
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				hasWord = true
+			}
+		}
+		if hasWord {
+			count++
+		}
+	}
+	return count
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,112 @@
+package merger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MarkdownFile is the data the header, footer and separator templates of a Markdown merge are executed with
+type MarkdownFile struct {
+	Index       int                    // Position of the file in the merge, starting at 1
+	Count       int                    // Number of files merged
+	Title       string                 // Section title, the summary link text or the file name without extension
+	Name        string                 // File name with extension
+	Path        string                 // Path of the file, or the name of a stream
+	Level       int                    // Heading level of the section, 1 unless nested in a summary
+	FrontMatter map[string]interface{} // Front matter of the file, empty without
+	ModTime     time.Time              // Modification time, zero for streams
+}
+
+// markdownTemplates are the parsed header, footer and separator templates of MarkdownOptions,
+// a nil template keeps the default output
+type markdownTemplates struct {
+	header, footer, separator *template.Template
+}
+
+// templateFuncs are the functions available to Markdown templates in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"repeat": func(s string, n int) string { return strings.Repeat(s, max(n, 0)) },
+	"upper":  strings.ToUpper,
+	"lower":  strings.ToLower,
+	"trim":   strings.TrimSpace,
+}
+
+// parseMarkdownTemplates parses the templates set in opts
+func parseMarkdownTemplates(opts MarkdownOptions) (*markdownTemplates, error) {
+	t := &markdownTemplates{}
+	for _, tmpl := range []struct {
+		name string
+		text string
+		dest **template.Template
+	}{
+		{"header", opts.Header, &t.header},
+		{"footer", opts.Footer, &t.footer},
+		{"separator", opts.Separator, &t.separator},
+	} {
+		if tmpl.text == "" {
+			continue
+		}
+		parsed, err := template.New(tmpl.name).Funcs(templateFuncs).Parse(tmpl.text)
+		if err != nil {
+			return nil, newError(ErrInvalidOptions, err, "Invalid %s template: %v", tmpl.name, err)
+		}
+		*tmpl.dest = parsed
+	}
+	return t, nil
+}
+
+// markdownFile returns the template data of the input at index i of count inputs, whose content is read
+func markdownFile(in Input, i, count int, content []byte) MarkdownFile {
+	file := MarkdownFile{
+		Index: i + 1,
+		Count: count,
+		Title: in.title(),
+		Name:  filepath.Base(in.displayName()),
+		Path:  in.displayName(),
+		Level: in.headingLevel(),
+	}
+	if fm, _, err := splitFrontMatter(content); err == nil && fm != nil {
+		file.FrontMatter = fm
+	} else {
+		file.FrontMatter = map[string]interface{}{}
+	}
+	if in.Reader == nil {
+		if info, err := os.Stat(in.Path); err == nil {
+			file.ModTime = info.ModTime()
+		}
+	}
+	return file
+}
+
+// renderTemplate renders tmpl for file as a block of lines: without surrounding newlines, and empty when the
+// template renders only white space
+func renderTemplate(tmpl *template.Template, file MarkdownFile) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, file); err != nil {
+		return "", newError(ErrInvalidOptions, err, "Cannot execute %s template for %s: %v", tmpl.Name(), file.Path, err)
+	}
+	if strings.TrimSpace(buf.String()) == "" {
+		return "", nil
+	}
+	return strings.Trim(buf.String(), "\r\n"), nil
+}
+
+// renderMarkdownTemplates renders the header, separator and footer templates that are set for file
+func renderMarkdownTemplates(t *markdownTemplates, file MarkdownFile) (header, separator, footer string, err error) {
+	for _, tmpl := range []struct {
+		t    *template.Template
+		dest *string
+	}{{t.header, &header}, {t.separator, &separator}, {t.footer, &footer}} {
+		if tmpl.t == nil {
+			continue
+		}
+		if *tmpl.dest, err = renderTemplate(tmpl.t, file); err != nil {
+			return "", "", "", err
+		}
+	}
+	return header, separator, footer, nil
+}
@@ -1,13 +1,17 @@
 package merger
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 )
 
@@ -19,11 +23,31 @@ type PDFFileInfo struct {
 
 // MergeResult stores merge operation result information
 type MergeResult struct {
-	Success      bool     `json:"success"`
-	OutputPath   string   `json:"outputPath,omitempty"`
-	MergedFiles  int      `json:"mergedFiles,omitempty"`
-	ErrorMessage string   `json:"errorMessage,omitempty"`
-	FilesList    []string `json:"filesList,omitempty"`
+	Success       bool          `json:"success"`
+	OutputPath    string        `json:"outputPath,omitempty"`
+	MergedFiles   int           `json:"mergedFiles,omitempty"`
+	ErrorMessage  string        `json:"errorMessage,omitempty"`
+	FilesList     []string      `json:"filesList,omitempty"`
+	SkippedFiles  []SkippedFile `json:"skippedFiles,omitempty"`  // Files left out of the merge, e.g. invalid PDFs with SkipInvalid
+	RepairedFiles []string      `json:"repairedFiles,omitempty"` // Files that were repaired before merging
+	Warnings      []Warning     `json:"warnings,omitempty"`      // Problems that did not stop the merge, e.g. skipped files
+	OutputSkipped bool          `json:"outputSkipped,omitempty"` // Nothing was merged because the output file exists and IfExists is ExistingSkip
+}
+
+// Warning describes a problem with an input file that did not stop the merge
+type Warning struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// addWarnings records a warning for every skipped and repaired file of r
+func (r *MergeResult) addWarnings() {
+	for _, skipped := range r.SkippedFiles {
+		r.Warnings = append(r.Warnings, Warning{Path: skipped.Path, Message: "Skipped: " + skipped.Reason})
+	}
+	for _, file := range r.RepairedFiles {
+		r.Warnings = append(r.Warnings, Warning{Path: file, Message: "Repaired before merging"})
+	}
 }
 
 // MarkdownFileInfo stores Markdown file information
@@ -32,103 +56,147 @@ type MarkdownFileInfo struct {
 	Title string `json:"title"`
 }
 
-// MergePDFs merges all PDF files in the specified directory
-func MergePDFs(inputDir, outputFile string, verbose bool) (*MergeResult, error) {
-	// Check if input directory exists
+// checkInputDir checks that inputDir exists and is a directory
+func checkInputDir(inputDir string) error {
 	info, err := os.Stat(inputDir)
 	if err != nil {
 		// Add error handling for absolute paths
-		errMsg := fmt.Sprintf("Cannot access input directory %s: %v", inputDir, err)
 		if os.IsNotExist(err) {
 			if filepath.IsAbs(inputDir) {
-				errMsg = fmt.Sprintf("Specified absolute path directory does not exist: %s", inputDir)
-			} else {
-				errMsg = fmt.Sprintf("Specified directory does not exist: %s", inputDir)
+				return newError(ErrInputNotFound, err, "Specified absolute path directory does not exist: %s", inputDir)
 			}
+			return newError(ErrInputNotFound, err, "Specified directory does not exist: %s", inputDir)
 		}
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: errMsg,
-		}, err
+		return fmt.Errorf("Cannot access input directory %s: %w", inputDir, err)
 	}
 
 	if !info.IsDir() {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("%s is not a directory", inputDir),
-		}, fmt.Errorf("%s is not a directory", inputDir)
+		return newError(ErrInputNotDir, nil, "%s is not a directory", inputDir)
 	}
+	return nil
+}
 
-	// Get all PDF files in the directory
-	var pdfFiles []string
-	err = filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.ToLower(filepath.Ext(path)) == ".pdf" {
-			pdfFiles = append(pdfFiles, path)
-		}
-		return nil
+// MergePDFs merges all PDF files in the specified directory
+func MergePDFs(inputDir, outputFile string, verbose bool) (*MergeResult, error) {
+	return MergePDFsWithValidation(inputDir, outputFile, DefaultValidationOptions(), verbose)
+}
+
+// MergePDFsWithValidation merges all PDF files in the specified directory, validating each file first
+func MergePDFsWithValidation(inputDir, outputFile string, validation ValidationOptions, verbose bool) (*MergeResult, error) {
+	return Merge(Options{
+		InputDir:   inputDir,
+		OutputFile: outputFile,
+		Format:     FormatPDF,
+		Verbose:    verbose,
+		PDF:        PDFOptions{Validation: validation},
 	})
+}
+
+// mergeValidatedPDFs runs the validation pass over inputs and writes the inputs that passed, merged, to w.
+// When base is set, the inputs are appended to it as an incremental update.
+func mergeValidatedPDFs(ctx context.Context, inputs []Input, base *Input, w io.Writer, opts Options) (*MergeResult, error) {
+	validated, err := validatePDFs(ctx, inputs, opts.PDF.Validation, opts.Logger, opts.Progress)
 	if err != nil {
 		return &MergeResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Error scanning directory: %v", err),
+			ErrorMessage: err.Error(),
 		}, err
 	}
 
-	if len(pdfFiles) == 0 {
+	if len(validated.inputs) == 0 {
+		err := newError(ErrNoValidFiles, nil, "No valid PDF files to merge")
 		return &MergeResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("No PDF files found in directory %s", inputDir),
-		}, fmt.Errorf("No PDF files found in directory %s", inputDir)
-	}
-
-	// Sort files in alphanumeric order
-	sort.Strings(pdfFiles)
-
-	if verbose {
-		fmt.Printf("Found %d PDF files, preparing to merge...\n", len(pdfFiles))
-		for i, file := range pdfFiles {
-			fmt.Printf("%d: %s\n", i+1, file)
-		}
+			ErrorMessage: err.Error(),
+			SkippedFiles: validated.skippedFiles,
+		}, err
 	}
 
-	// Create configuration
-	conf := model.NewDefaultConfiguration()
-
 	// Execute merge
-	// Set dividerPage to false, meaning don't add separator pages between merged PDFs
-	err = api.MergeCreateFile(pdfFiles, outputFile, false, conf)
+	if base != nil {
+		err = appendPDFs(ctx, *base, validated.inputs, opts.PDF.InsertAt, w, opts.Progress)
+	} else {
+		err = mergePDFs(ctx, validated.inputs, w, opts.Progress)
+	}
 	if err != nil {
+		if !errors.Is(err, ErrOutputFailed) && !errors.Is(err, ErrInvalidOptions) {
+			err = newError(ErrMergeFailed, err, "Failed to merge PDF files: %v", err)
+		}
 		return &MergeResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to merge PDF files: %v", err),
+			ErrorMessage: err.Error(),
+			SkippedFiles: validated.skippedFiles,
 		}, err
 	}
 
 	return &MergeResult{
-		Success:     true,
-		OutputPath:  outputFile,
-		MergedFiles: len(pdfFiles),
-		FilesList:   pdfFiles,
+		Success:       true,
+		MergedFiles:   len(validated.inputs),
+		FilesList:     inputNames(validated.inputs),
+		SkippedFiles:  validated.skippedFiles,
+		RepairedFiles: validated.repairedFiles,
 	}, nil
 }
 
+// mergePDFs merges the PDF inputs and writes the result to w, checking ctx between files and while writing
+func mergePDFs(ctx context.Context, inputs []Input, w io.Writer, progress ProgressFunc) error {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.MERGECREATE
+	conf.ValidationMode = model.ValidationRelaxed
+
+	dest, err := readPDFInput(ctx, inputs[0], conf)
+	if err != nil {
+		return err
+	}
+	if conf.CreateBookmarks {
+		if err := pdfcpu.EnsureOutlines(dest, filepath.Base(inputs[0].displayName()), false); err != nil {
+			return err
+		}
+	}
+	if dest.XRefTable.Version() < model.V20 {
+		dest.EnsureVersionForWriting()
+	}
+	progress.report(ProgressEvent{Stage: ProgressMerged, File: inputs[0].displayName(), Current: 1, Total: len(inputs), Pages: dest.PageCount})
+
+	for i := 1; i < len(inputs); i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		src, err := readPDFInput(ctx, inputs[i], conf)
+		if err != nil {
+			return err
+		}
+		if dest.XRefTable.Version() < model.V20 && src.XRefTable.Version() == model.V20 {
+			return pdfcpu.ErrUnsupportedVersion
+		}
+
+		// Set dividerPage to false, meaning don't add separator pages between merged PDFs
+		if err := pdfcpu.MergeXRefTables(filepath.Base(inputs[i].displayName()), src, dest, false, false); err != nil {
+			return err
+		}
+		progress.report(ProgressEvent{Stage: ProgressMerged, File: inputs[i].displayName(), Current: i + 1, Total: len(inputs), Pages: dest.PageCount})
+	}
+
+	if conf.OptimizeBeforeWriting {
+		if err := api.OptimizeContext(dest); err != nil {
+			return err
+		}
+	}
+
+	return api.WriteContext(dest, &contextWriter{ctx: ctx, w: &progressWriter{w: w, progress: progress}})
+}
+
 // GetPDFFiles gets all PDF files in the specified directory
 func GetPDFFiles(inputDir string) ([]PDFFileInfo, error) {
 	// Check if input directory exists
-	info, err := os.Stat(inputDir)
-	if err != nil {
-		return nil, fmt.Errorf("Cannot access input directory %s: %v", inputDir, err)
-	}
-	if !info.IsDir() {
-		return nil, fmt.Errorf("%s is not a directory", inputDir)
+	if err := checkInputDir(inputDir); err != nil {
+		return nil, err
 	}
 
 	// Get all PDF files in the directory
 	var pdfInfos []PDFFileInfo
-	err = filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -142,7 +210,7 @@ func GetPDFFiles(inputDir string) ([]PDFFileInfo, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error scanning directory: %v", err)
+		return nil, fmt.Errorf("Error scanning directory: %w", err)
 	}
 
 	// Sort files in alphanumeric order
@@ -153,132 +221,135 @@ func GetPDFFiles(inputDir string) ([]PDFFileInfo, error) {
 	return pdfInfos, nil
 }
 
-// MergeMarkdownFiles merges all Markdown files in the specified directory
+// MergeMarkdownFiles merges all Markdown files in the specified directory, resolving their include directives
 func MergeMarkdownFiles(inputDir, outputFile string, addTitles bool, verbose bool) (*MergeResult, error) {
-	// Check if input directory exists
-	info, err := os.Stat(inputDir)
-	if err != nil {
-		// Add error handling for absolute paths
-		errMsg := fmt.Sprintf("Cannot access input directory %s: %v", inputDir, err)
-		if os.IsNotExist(err) {
-			if filepath.IsAbs(inputDir) {
-				errMsg = fmt.Sprintf("Specified absolute path directory does not exist: %s", inputDir)
-			} else {
-				errMsg = fmt.Sprintf("Specified directory does not exist: %s", inputDir)
-			}
-		}
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: errMsg,
-		}, err
-	}
-
-	if !info.IsDir() {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("%s is not a directory", inputDir),
-		}, fmt.Errorf("%s is not a directory", inputDir)
-	}
-
-	// Get all Markdown files in the directory
-	var mdFiles []string
-	err = filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		ext := strings.ToLower(filepath.Ext(path))
-		if !info.IsDir() && (ext == ".md" || ext == ".markdown") {
-			mdFiles = append(mdFiles, path)
-		}
-		return nil
+	return Merge(Options{
+		InputDir:   inputDir,
+		OutputFile: outputFile,
+		Format:     FormatMarkdown,
+		Verbose:    verbose,
+		Markdown:   MarkdownOptions{AddTitles: addTitles, ResolveIncludes: true},
 	})
-	if err != nil {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("Error scanning directory: %v", err),
-		}, err
-	}
-
-	if len(mdFiles) == 0 {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("No Markdown files found in directory %s", inputDir),
-		}, fmt.Errorf("No Markdown files found in directory %s", inputDir)
-	}
-
-	// Sort files in alphanumeric order
-	sort.Strings(mdFiles)
+}
 
-	if verbose {
-		fmt.Printf("Found %d Markdown files, preparing to merge...\n", len(mdFiles))
-		for i, file := range mdFiles {
-			fmt.Printf("%d: %s\n", i+1, file)
-		}
+// mergeMarkdown concatenates the Markdown inputs and writes the result to w
+func mergeMarkdown(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	if opts.Markdown.LineEndings == LineEndingCRLF {
+		w = crlfWriter{w: w}
 	}
+	result, _, err := concatMarkdown(ctx, inputs, w, opts, false)
+	return result, err
+}
 
-	// Create output file
-	outFile, err := os.Create(outputFile)
+// concatMarkdown writes the Markdown inputs to w, with the titles and separators set in opts, leaving out their
+// front matter if stripFrontMatter is set. It returns the offset in the output at which the content of each
+// input starts.
+func concatMarkdown(ctx context.Context, inputs []Input, w io.Writer, opts Options, stripFrontMatter bool) (*MergeResult, []int64, error) {
+	out := &progressWriter{w: w, progress: opts.Progress}
+	starts := make([]int64, len(inputs))
+	templates, err := parseMarkdownTemplates(opts.Markdown)
 	if err != nil {
 		return &MergeResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Cannot create output file: %v", err),
-		}, err
+			ErrorMessage: err.Error(),
+		}, nil, err
 	}
-	defer outFile.Close()
 
 	// Merge all Markdown files
-	for i, mdFile := range mdFiles {
+	for i, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, nil, err
+		}
+
 		// Read Markdown file content
-		content, err := os.ReadFile(mdFile)
+		content, err := readMarkdown(in, opts)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFile) {
+				err = fmt.Errorf("Failed to read file %s: %w", in.displayName(), err)
+			}
+			return &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, nil, err
+		}
+		file := markdownFile(in, i, len(inputs), content)
+		if stripFrontMatter {
+			if _, body, err := splitFrontMatter(content); err == nil {
+				content = body
+			}
+		}
+		content = addHeadingLevels(content, in.headingLevel()-1)
+		header, separator, footer, err := renderMarkdownTemplates(templates, file)
 		if err != nil {
 			return &MergeResult{
 				Success:      false,
-				ErrorMessage: fmt.Sprintf("Failed to read file %s: %v", mdFile, err),
-			}, err
+				ErrorMessage: err.Error(),
+			}, nil, err
 		}
 
-		// If titles should be added, add filename as title
-		if addTitles {
-			title := strings.TrimSuffix(filepath.Base(mdFile), filepath.Ext(mdFile))
-
-			// If not the first file, add separator first
-			if i > 0 {
-				outFile.WriteString("\n\n---\n\n")
+		// If not the first file, add separator first
+		if i > 0 {
+			switch {
+			case templates.separator != nil && separator != "":
+				io.WriteString(out, "\n"+separator+"\n\n")
+			case templates.separator != nil:
+				io.WriteString(out, "\n")
+			case opts.Markdown.AddTitles:
+				io.WriteString(out, "\n---\n\n")
+			default:
+				// If not adding titles, a blank line separates the files
+				io.WriteString(out, "\n")
 			}
+		}
 
-			// Write title
-			outFile.WriteString(fmt.Sprintf("# %s\n\n", title))
-		} else if i > 0 {
-			// If not adding titles but not the first file, add two newlines as separator
-			outFile.WriteString("\n\n")
+		// Write the header, or the filename as title if titles should be added
+		switch {
+		case templates.header != nil && header != "":
+			io.WriteString(out, header+"\n\n")
+		case templates.header == nil && opts.Markdown.AddTitles:
+			fmt.Fprintf(out, "%s %s\n\n", strings.Repeat("#", in.headingLevel()), in.title())
 		}
 
-		// Write file content
-		outFile.Write(content)
+		// Write file content, ending with a newline so that the separator starts on a line of its own
+		starts[i] = out.written
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			content = append(content, '\n')
+		}
+		if footer != "" {
+			content = append(content, "\n"+footer+"\n"...)
+		}
+		if _, err := out.Write(content); err != nil {
+			if !errors.Is(err, ErrOutputFailed) {
+				err = newError(ErrOutputFailed, err, "Cannot write output: %v", err)
+			}
+			return &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, nil, err
+		}
+		opts.Progress.report(ProgressEvent{Stage: ProgressMerged, File: in.displayName(), Current: i + 1, Total: len(inputs)})
 	}
 
 	return &MergeResult{
 		Success:     true,
-		OutputPath:  outputFile,
-		MergedFiles: len(mdFiles),
-		FilesList:   mdFiles,
-	}, nil
+		MergedFiles: len(inputs),
+		FilesList:   inputNames(inputs),
+	}, starts, nil
 }
 
 // GetMarkdownFiles gets all Markdown files in the specified directory
 func GetMarkdownFiles(inputDir string) ([]MarkdownFileInfo, error) {
 	// Check if input directory exists
-	info, err := os.Stat(inputDir)
-	if err != nil {
-		return nil, fmt.Errorf("Cannot access input directory %s: %v", inputDir, err)
-	}
-	if !info.IsDir() {
-		return nil, fmt.Errorf("%s is not a directory", inputDir)
+	if err := checkInputDir(inputDir); err != nil {
+		return nil, err
 	}
 
 	// Get all Markdown files in the directory
 	var mdInfos []MarkdownFileInfo
-	err = filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -293,7 +364,7 @@ func GetMarkdownFiles(inputDir string) ([]MarkdownFileInfo, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("Error scanning directory: %v", err)
+		return nil, fmt.Errorf("Error scanning directory: %w", err)
 	}
 
 	// Sort files in alphanumeric order
@@ -306,172 +377,27 @@ func GetMarkdownFiles(inputDir string) ([]MarkdownFileInfo, error) {
 
 // MergePDFFiles merges the specified list of PDF files
 func MergePDFFiles(files []string, outputFile string, verbose bool) (*MergeResult, error) {
-	if len(files) == 0 {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: "No files provided",
-		}, fmt.Errorf("No files provided")
-	}
-
-	// Validate that each file exists and is a PDF
-	validFiles := make([]string, 0, len(files))
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			if verbose {
-				fmt.Printf("Warning: Cannot access file %s: %v, skipped\n", file, err)
-			}
-			continue
-		}
-
-		if info.IsDir() {
-			if verbose {
-				fmt.Printf("Warning: %s is a directory, not a file, skipped\n", file)
-			}
-			continue
-		}
-
-		if strings.ToLower(filepath.Ext(file)) != ".pdf" {
-			if verbose {
-				fmt.Printf("Warning: %s is not a PDF file, skipped\n", file)
-			}
-			continue
-		}
-
-		validFiles = append(validFiles, file)
-	}
-
-	if len(validFiles) == 0 {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: "No valid PDF files to merge",
-		}, fmt.Errorf("No valid PDF files to merge")
-	}
-
-	if verbose {
-		fmt.Printf("Found %d valid PDF files, preparing to merge...\n", len(validFiles))
-		for i, file := range validFiles {
-			fmt.Printf("%d: %s\n", i+1, file)
-		}
-	}
-
-	// Create configuration
-	conf := model.NewDefaultConfiguration()
-
-	// Execute merge
-	err := api.MergeCreateFile(validFiles, outputFile, false, conf)
-	if err != nil {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to merge PDF files: %v", err),
-		}, err
-	}
+	return MergePDFFilesWithValidation(files, outputFile, DefaultValidationOptions(), verbose)
+}
 
-	return &MergeResult{
-		Success:     true,
-		OutputPath:  outputFile,
-		MergedFiles: len(validFiles),
-		FilesList:   validFiles,
-	}, nil
+// MergePDFFilesWithValidation merges the specified list of PDF files, validating each file first
+func MergePDFFilesWithValidation(files []string, outputFile string, validation ValidationOptions, verbose bool) (*MergeResult, error) {
+	return Merge(Options{
+		Files:      files,
+		OutputFile: outputFile,
+		Format:     FormatPDF,
+		Verbose:    verbose,
+		PDF:        PDFOptions{Validation: validation},
+	})
 }
 
-// MergeMarkdownFilesList merges the specified list of Markdown files
+// MergeMarkdownFilesList merges the specified list of Markdown files, resolving their include directives
 func MergeMarkdownFilesList(files []string, outputFile string, addTitles bool, verbose bool) (*MergeResult, error) {
-	if len(files) == 0 {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: "No files provided",
-		}, fmt.Errorf("No files provided")
-	}
-
-	// Validate that each file exists and is a Markdown file
-	validFiles := make([]string, 0, len(files))
-	for _, file := range files {
-		info, err := os.Stat(file)
-		if err != nil {
-			if verbose {
-				fmt.Printf("Warning: Cannot access file %s: %v, skipped\n", file, err)
-			}
-			continue
-		}
-
-		if info.IsDir() {
-			if verbose {
-				fmt.Printf("Warning: %s is a directory, not a file, skipped\n", file)
-			}
-			continue
-		}
-
-		ext := strings.ToLower(filepath.Ext(file))
-		if ext != ".md" && ext != ".markdown" {
-			if verbose {
-				fmt.Printf("Warning: %s is not a Markdown file, skipped\n", file)
-			}
-			continue
-		}
-
-		validFiles = append(validFiles, file)
-	}
-
-	if len(validFiles) == 0 {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: "No valid Markdown files to merge",
-		}, fmt.Errorf("No valid Markdown files to merge")
-	}
-
-	if verbose {
-		fmt.Printf("Found %d valid Markdown files, preparing to merge...\n", len(validFiles))
-		for i, file := range validFiles {
-			fmt.Printf("%d: %s\n", i+1, file)
-		}
-	}
-
-	// Create output file
-	outFile, err := os.Create(outputFile)
-	if err != nil {
-		return &MergeResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("Cannot create output file: %v", err),
-		}, err
-	}
-	defer outFile.Close()
-
-	// Merge all Markdown files
-	for i, mdFile := range validFiles {
-		// Read Markdown file content
-		content, err := os.ReadFile(mdFile)
-		if err != nil {
-			return &MergeResult{
-				Success:      false,
-				ErrorMessage: fmt.Sprintf("Failed to read file %s: %v", mdFile, err),
-			}, err
-		}
-
-		// If titles should be added, add filename as title
-		if addTitles {
-			title := strings.TrimSuffix(filepath.Base(mdFile), filepath.Ext(mdFile))
-
-			// If not the first file, add separator first
-			if i > 0 {
-				outFile.WriteString("\n\n---\n\n")
-			}
-
-			// Write title
-			outFile.WriteString(fmt.Sprintf("# %s\n\n", title))
-		} else if i > 0 {
-			// If not adding titles but not the first file, add two newlines as separator
-			outFile.WriteString("\n\n")
-		}
-
-		// Write file content
-		outFile.Write(content)
-	}
-
-	return &MergeResult{
-		Success:     true,
-		OutputPath:  outputFile,
-		MergedFiles: len(validFiles),
-		FilesList:   validFiles,
-	}, nil
+	return Merge(Options{
+		Files:      files,
+		OutputFile: outputFile,
+		Format:     FormatMarkdown,
+		Verbose:    verbose,
+		Markdown:   MarkdownOptions{AddTitles: addTitles, ResolveIncludes: true},
+	})
 }
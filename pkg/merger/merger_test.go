@@ -0,0 +1,43 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeMarkdownSeparators(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"a.md": "# A\n\ntext a\n", "b.md": "text b", "c.md": "c\n\n"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A blank line separates every file from the rule or the next file, whether or not it ends with a newline
+	tests := []struct {
+		addTitles bool
+		want      string
+	}{
+		{true, "# a\n\n# A\n\ntext a\n\n---\n\n# b\n\ntext b\n\n---\n\n# c\n\nc\n\n"},
+		{false, "# A\n\ntext a\n\ntext b\n\nc\n\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		_, err := MergeContext(context.Background(), Options{
+			InputDir: dir,
+			Output:   &out,
+			Format:   FormatMarkdown,
+			Markdown: MarkdownOptions{AddTitles: tt.addTitles},
+		})
+		if err != nil {
+			t.Fatalf("merge with titles %v error = %v", tt.addTitles, err)
+		}
+		if got := out.String(); got != tt.want {
+			t.Errorf("merge with titles %v = %q, want %q", tt.addTitles, got, tt.want)
+		}
+	}
+}
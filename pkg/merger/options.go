@@ -0,0 +1,693 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Format identifies the type of documents being merged
+type Format string
+
+const (
+	// FormatPDF merges PDF files into a single PDF
+	FormatPDF Format = "pdf"
+	// FormatMarkdown concatenates Markdown files into a single Markdown file
+	FormatMarkdown Format = "markdown"
+	// FormatHTML merges Markdown files and renders them as a single self-contained HTML page
+	FormatHTML Format = "html"
+	// FormatEPUB merges Markdown files into an EPUB 3 book, with one chapter per file
+	FormatEPUB Format = "epub"
+	// FormatText concatenates plain text files such as logs
+	FormatText Format = "text"
+	// FormatCSV concatenates CSV files, keeping the header row of the first file only
+	FormatCSV Format = "csv"
+	// FormatCode concatenates source files such as SQL scripts, each introduced by a comment naming it
+	FormatCode Format = "code"
+)
+
+// ParseFormat converts a string to a registered Format, an empty string leaves the format to be inferred from
+// the output file name
+func ParseFormat(s string) (Format, error) {
+	name := strings.ToLower(s)
+	if name == "" {
+		return "", nil
+	}
+	var names []string
+	for _, spec := range Formats() {
+		if string(spec.Format) == name || slices.Contains(spec.Aliases, name) {
+			return spec.Format, nil
+		}
+		names = append(names, string(spec.Format))
+	}
+	return "", newError(ErrInvalidOptions, nil, "Unknown format %q, must be %s or %s", s,
+		strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// displayName returns the name of the format used in messages
+func (f Format) displayName() string {
+	if spec, ok := lookupFormat(f); ok {
+		return spec.DisplayName
+	}
+	return string(f)
+}
+
+// inputFormat returns the format of the files merged into a document of format f
+func (f Format) inputFormat() Format {
+	if format := FormatForFileType(f.InputType()); format != "" {
+		return format
+	}
+	return f
+}
+
+// SortOrder selects how input files are ordered before merging
+type SortOrder string
+
+const (
+	// SortAlphanumeric sorts files by path, comparing byte by byte
+	SortAlphanumeric SortOrder = "alphanumeric"
+	// SortNatural sorts files by path, comparing runs of digits by their numeric value (2.pdf before 10.pdf)
+	SortNatural SortOrder = "natural"
+	// SortNone keeps the order in which files were given, or found in the input directory
+	SortNone SortOrder = "none"
+)
+
+// ParseSortOrder converts a string to a SortOrder, an empty string selects the default order
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch SortOrder(strings.ToLower(s)) {
+	case "":
+		return "", nil
+	case SortAlphanumeric:
+		return SortAlphanumeric, nil
+	case SortNatural:
+		return SortNatural, nil
+	case SortNone:
+		return SortNone, nil
+	}
+	return "", newError(ErrInvalidOptions, nil, "Unknown sort order %q, must be alphanumeric, natural or none", s)
+}
+
+// PDFOptions stores settings that only apply when merging PDF files
+type PDFOptions struct {
+	Validation ValidationOptions
+	Append     bool // Add the inputs to the existing OutputFile with an incremental update, keeping its content, bookmarks and metadata
+	InsertAt   int  // With Append, page of the existing OutputFile the inputs are inserted before, 0 adds them after the last page
+}
+
+// MarkdownOptions stores settings that only apply when merging Markdown files, into Markdown, HTML or EPUB
+type MarkdownOptions struct {
+	AddTitles       bool // Add the filename of each file as a level 1 heading
+	ResolveIncludes bool // Replace include directives with the content of the files they name
+	IncludeDepth    int  // Deepest nesting of includes, 8 when 0
+
+	Encoding    string     // Encoding of the inputs, e.g. gbk or utf-16le, detected for each file when empty or "auto"
+	LineEndings LineEnding // Line breaks of the merged file, those of the inputs are kept when empty
+
+	// Go text/template templates executed with a MarkdownFile, each written on lines of its own
+	Header    string // Written before each file instead of the title added by AddTitles
+	Footer    string // Written after each file
+	Separator string // Written between files, with the file that follows, instead of the default separator
+}
+
+// HTMLOptions stores settings that only apply when rendering merged Markdown files as HTML
+type HTMLOptions struct {
+	Title        string // Page title, defaults to the first level 1 heading, then to the output file name
+	InlineImages bool   // Embed local images as data URIs, otherwise their paths are made relative to the output file
+}
+
+// TextOptions stores settings that only apply to plain text, CSV and source code merges
+type TextOptions struct {
+	Banners     bool       // Write the name of each file before its content, in a comment of its language for source code
+	Encoding    string     // Encoding of the inputs, e.g. gbk or utf-16le, detected for each file when empty or "auto"
+	LineEndings LineEnding // Line breaks of the merged file, those of the inputs are kept when empty
+}
+
+// Options configures a merge performed by Merge.
+// New settings are added as fields, so the zero value of every field keeps the previous behavior.
+type Options struct {
+	// Inputs: files found in InputDir (searched recursively, or the files of a ZIP or TAR archive), followed by
+	// the chapters listed in Summary, followed by Files (where archives stand for their files), followed by Inputs
+	InputDir   string
+	Summary    string // SUMMARY.md file listing Markdown files in reading order, titled and nested as listed
+	Files      []string
+	FileInputs map[string]Input // Documents merged as given in place of the entries of Files with these names, e.g. stdin for "-"
+	Inputs     []Input          // Documents read from streams or files, merged as given without type checks or filtering
+	Include    []string         // Glob patterns matched against file names, only matching files are merged when set
+	Exclude    []string         // Glob patterns matched against file names, matching files are left out
+
+	// Ordering: empty sorts directory inputs alphanumerically and keeps Files and Inputs in the given order
+	Order SortOrder
+
+	// Output
+	OutputFile string         // Written through a temporary file that replaces it once the merge succeeds
+	IfExists   ExistingOutput // What to do when OutputFile exists, empty overwrites it
+	Output     io.Writer      // Receives the merged document instead of OutputFile when set
+	Format     Format         // Inferred from the OutputFile extension when empty
+	Verbose    bool           // Log with slog.Default() when Logger is not set
+	Logger     *slog.Logger   // Receives warnings and progress messages, discarded when nil and Verbose is off
+	Progress   ProgressFunc   // Receives progress events, optional
+
+	// Format-specific settings
+	PDF      PDFOptions
+	Markdown MarkdownOptions
+	HTML     HTMLOptions
+	EPUB     EPUBOptions
+	Text     TextOptions
+	Archive  ArchiveOptions // Limits of the ZIP and TAR archives given as InputDir or in Files
+
+	appendTo *Input // Existing PDF document the inputs are added to, set by merge with PDF.Append
+}
+
+// Merge merges the input files described by opts into opts.OutputFile or opts.Output
+func Merge(opts Options) (*MergeResult, error) {
+	return MergeContext(context.Background(), opts)
+}
+
+// MergeContext merges the input files described by opts into opts.OutputFile or opts.Output.
+// Scanning, validation and writing stop when ctx is cancelled, the temporary output file is removed
+// and the returned error matches ctx.Err() with errors.Is.
+func MergeContext(ctx context.Context, opts Options) (*MergeResult, error) {
+	result, err := merge(ctx, opts)
+	if err != nil {
+		if cancelErr := canceledError(ctx); cancelErr != nil {
+			err = cancelErr
+			result.ErrorMessage = err.Error()
+		}
+	}
+	return result, err
+}
+
+// merge performs the merge for MergeContext
+func merge(ctx context.Context, opts Options) (*MergeResult, error) {
+	opts.Logger = opts.logger()
+
+	format, err := resolveFormat(opts)
+	if err == nil {
+		err = checkAppendOptions(opts, format)
+	}
+	if err == nil && opts.Output == nil && !opts.PDF.Append {
+		err = checkExistingOutput(opts.OutputFile, opts.IfExists)
+	}
+	if err != nil {
+		if errors.Is(err, ErrOutputExists) && opts.IfExists == ExistingSkip {
+			return skippedOutputResult(opts), nil
+		}
+		return &MergeResult{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, err
+	}
+
+	inputs, skipped, err := collectInputs(ctx, opts, format)
+	if err != nil {
+		result := &MergeResult{
+			Success:      false,
+			ErrorMessage: err.Error(),
+			SkippedFiles: skipped,
+		}
+		result.addWarnings()
+		return result, err
+	}
+
+	opts.Logger.Info("Found files to merge", "count", len(inputs), "format", format.displayName())
+	for i, in := range inputs {
+		opts.Logger.Info("Input file", "index", i+1, "path", in.displayName())
+	}
+
+	// With PDF.Append, an existing output file is the document the inputs are added to
+	var base *Input
+	ifExists := opts.IfExists
+	if opts.PDF.Append {
+		ifExists = ExistingOverwrite
+		if _, statErr := os.Stat(opts.OutputFile); statErr == nil {
+			base = &Input{Path: opts.OutputFile}
+			opts.Logger.Info("Appending to existing file", "path", opts.OutputFile)
+		} else if opts.PDF.InsertAt > 0 {
+			err = newError(ErrInvalidOptions, statErr, "Cannot insert before page %d, %s does not exist", opts.PDF.InsertAt, opts.OutputFile)
+			result := &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+				SkippedFiles: skipped,
+			}
+			result.addWarnings()
+			return result, err
+		}
+	}
+
+	// Write to opts.Output, or to a temporary file that replaces the output file on success
+	out := opts.Output
+	var file *atomicFile
+	if out == nil {
+		file, err = createAtomicFile(opts.OutputFile, ifExists)
+		if err != nil {
+			result := &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+				SkippedFiles: skipped,
+			}
+			result.addWarnings()
+			return result, err
+		}
+		out = file
+	}
+
+	// Inputs of other file types are converted to the type the format merges, then merged by the format
+	var result *MergeResult
+	opts.appendTo = base
+	if inputs, err = convertInputs(ctx, inputs, format); err != nil {
+		result = &MergeResult{
+			Success:      false,
+			ErrorMessage: err.Error(),
+			SkippedFiles: skipped,
+		}
+		result.addWarnings()
+	} else {
+		spec, _ := lookupFormat(format)
+		result, err = spec.Merge(ctx, inputs, out, opts)
+	}
+
+	if file != nil {
+		if err != nil {
+			file.abort()
+		} else if err = file.commit(); err != nil {
+			if errors.Is(err, ErrOutputExists) && opts.IfExists == ExistingSkip {
+				return skippedOutputResult(opts), nil
+			}
+			result.Success = false
+			result.ErrorMessage = err.Error()
+		} else {
+			result.OutputPath = opts.OutputFile
+		}
+	}
+
+	result.SkippedFiles = append(skipped, result.SkippedFiles...)
+	result.addWarnings()
+	return result, err
+}
+
+// skippedOutputResult returns the result of a merge skipped because the output file exists
+func skippedOutputResult(opts Options) *MergeResult {
+	opts.Logger.Warn("Output file already exists, merge skipped", "path", opts.OutputFile)
+	return &MergeResult{
+		Success:       true,
+		OutputPath:    opts.OutputFile,
+		OutputSkipped: true,
+		Warnings:      []Warning{{Path: opts.OutputFile, Message: "Output file already exists, not overwritten"}},
+	}
+}
+
+// logger returns opts.Logger, or the logger selected by opts.Verbose when it is not set
+func (opts Options) logger() *slog.Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	if opts.Verbose {
+		return slog.Default()
+	}
+	return slog.New(slog.DiscardHandler)
+}
+
+// checkAppendOptions checks that the append settings of opts can be used with format
+func checkAppendOptions(opts Options, format Format) error {
+	if !opts.PDF.Append {
+		if opts.PDF.InsertAt != 0 {
+			return newError(ErrInvalidOptions, nil, "Inserting at a page requires appending")
+		}
+		return nil
+	}
+	if format != FormatPDF {
+		return newError(ErrInvalidOptions, nil, "Appending is only supported for PDF files")
+	}
+	if opts.Output != nil {
+		return newError(ErrInvalidOptions, nil, "Appending requires an output file")
+	}
+	if opts.PDF.InsertAt < 0 {
+		return newError(ErrInvalidOptions, nil, "Invalid page %d to insert at", opts.PDF.InsertAt)
+	}
+	return nil
+}
+
+// resolveFormat checks the output settings and returns opts.Format, or the format matching the extension of opts.OutputFile
+func resolveFormat(opts Options) (Format, error) {
+	if opts.Output == nil && opts.OutputFile == "" {
+		return "", newError(ErrInvalidOptions, nil, "No output file specified")
+	}
+
+	if opts.Format == "" {
+		if format := FormatForPath(opts.OutputFile); format != "" {
+			return format, nil
+		}
+		return "", newError(ErrInvalidOptions, nil, "Cannot determine output format from %q, set Format", opts.OutputFile)
+	}
+	if _, ok := lookupFormat(opts.Format); !ok {
+		return "", newError(ErrInvalidOptions, nil, "Unknown format %q", opts.Format)
+	}
+	return opts.Format, nil
+}
+
+// collectInputs returns the files to merge: files of the given format in opts.InputDir followed by the chapters
+// of opts.Summary and opts.Files, filtered by the include and exclude patterns and sorted according to opts.Order.
+// opts.Inputs are appended as given. Entries of opts.Summary and opts.Files that cannot be merged are returned
+// as skipped files. File types are detected from the content, files whose content contradicts their extension
+// are returned as *InvalidFileError values unless PDF.Validation.SkipInvalid is set.
+func collectInputs(ctx context.Context, opts Options, format Format) ([]Input, []SkippedFile, error) {
+	if opts.InputDir == "" && opts.Summary == "" && len(opts.Files) == 0 && len(opts.Inputs) == 0 {
+		return nil, nil, newError(ErrNoInputFiles, nil, "No files provided")
+	}
+	if opts.Summary != "" && format.inputFormat() != FormatMarkdown {
+		return nil, nil, newError(ErrInvalidOptions, nil, "A summary file can only list Markdown files, not %s files", format.inputFormat().displayName())
+	}
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, nil, newError(ErrInvalidOptions, err, "Invalid file pattern %q", pattern)
+		}
+	}
+
+	var files []string
+	var skipped []SkippedFile
+	var invalid []error
+	skip := func(file, reason string) {
+		opts.Logger.Warn("Input file skipped", "path", file, "reason", reason)
+		skipped = append(skipped, SkippedFile{Path: file, Reason: reason})
+	}
+
+	// fail records a file that cannot be merged. A file failing validation, e.g. with a content that
+	// contradicts its extension, is skipped with PDF.Validation.SkipInvalid like a damaged PDF file,
+	// and reported as an error otherwise.
+	fail := func(file string, err error) {
+		var invalidErr *InvalidFileError
+		switch {
+		case errors.As(err, &invalidErr) && !opts.PDF.Validation.SkipInvalid:
+			opts.Logger.Warn("File failed validation", "path", file, "reason", invalidErr.Reason)
+			invalid = append(invalid, err)
+		case invalidErr != nil:
+			skip(file, invalidErr.Reason)
+		default:
+			skip(file, fmt.Sprintf("Cannot read file: %v", err))
+		}
+	}
+
+	// detect returns the type of a file from its content
+	detect := func(file string) (string, bool) {
+		fileType, err := DetectFileType(file)
+		if err != nil {
+			fail(file, err)
+			return "", false
+		}
+		return fileType, true
+	}
+
+	// Directory scans only pick up files of the type the format merges, and for some formats with the
+	// extension of the output file. A SUMMARY.md is a table of contents, not a chapter.
+	scanExt := format.scanExtension(opts.OutputFile)
+	includes := format.InputType() == "markdown" && opts.Markdown.ResolveIncludes
+	scanned := func(name string) bool {
+		if format.InputType() == "markdown" && strings.EqualFold(filepath.Base(name), summaryFileName) {
+			return false
+		}
+		return fileTypeForPath(name) == format.InputType() && matchesPatterns(name, opts) &&
+			(scanExt == "" || strings.EqualFold(filepath.Ext(name), scanExt))
+	}
+	// includable reports whether an archive entry may be included by a Markdown file of the archive
+	includable := func(name string) bool {
+		t, _ := lookupFileType(fileTypeForPath(name))
+		return includes && t.Text
+	}
+
+	// archiveInputs returns the files of an archive that a directory scan would pick up, named after the
+	// archive and sorted like the files of a directory
+	archiveInputs := func(file string) []Input {
+		entries, err := readArchive(ctx, file, opts.Archive, func(name string) bool {
+			return scanned(name) || includable(name)
+		})
+		if err != nil {
+			fail(file, err)
+			return nil
+		}
+		files := make(map[string][]byte, len(entries))
+		for _, entry := range entries {
+			files[entry.name] = entry.data
+		}
+		var archived []Input
+		for _, entry := range entries {
+			name := file + "/" + entry.name
+			if !scanned(entry.name) {
+				continue
+			}
+			fileType, err := SniffFileType(entry.data[:min(len(entry.data), sniffLength)], name)
+			if err != nil {
+				fail(name, err)
+				continue
+			}
+			if fileType != format.InputType() {
+				// The extension named the type, e.g. a .txt file holding a PDF document
+				fail(name, contentMismatch(name, fileType, format.InputType()))
+				continue
+			}
+			archived = append(archived, Input{
+				Name:     name,
+				Reader:   bytes.NewReader(entry.data),
+				fileType: fileType,
+				archived: &archivedFile{archive: file, name: entry.name, files: files},
+			})
+			opts.Progress.report(ProgressEvent{Stage: ProgressScanned, File: name, Current: len(archived)})
+		}
+		order := opts.Order
+		if order == "" {
+			order = SortAlphanumeric
+		}
+		sortInputs(archived, order)
+		return archived
+	}
+
+	// checkFile returns the type of a listed file and whether it exists, has a type the format accepts
+	// and passes the patterns. Archives are accepted when archives is set, the patterns apply to their files.
+	checkFile := func(file string, archives bool) (string, bool) {
+		info, err := os.Stat(file)
+		if err != nil {
+			skip(file, fmt.Sprintf("Cannot access file: %v", err))
+			return "", false
+		}
+
+		if info.IsDir() {
+			skip(file, "Is a directory, not a file")
+			return "", false
+		}
+
+		fileType, ok := detect(file)
+		if !ok {
+			return "", false
+		}
+		if archives && fileType == archiveFileType {
+			return fileType, true
+		}
+		if !format.Accepts(fileType) {
+			skip(file, fmt.Sprintf("Not a %s file", format.acceptedTypes()))
+			return "", false
+		}
+
+		if !matchesPatterns(file, opts) {
+			opts.Logger.Debug("Input file filtered out by include/exclude patterns", "path", file)
+			return "", false
+		}
+		return fileType, true
+	}
+
+	// An archive given as input directory is scanned like a directory
+	var archived []Input
+	if opts.InputDir != "" && IsArchive(opts.InputDir) {
+		archived = archiveInputs(opts.InputDir)
+	} else if opts.InputDir != "" {
+		// Check if input directory exists
+		if err := checkInputDir(opts.InputDir); err != nil {
+			return nil, nil, err
+		}
+
+		// The output file may be in the scanned directory, it is never an input
+		output := newOutputMatcher(opts.OutputFile)
+		err := filepath.Walk(opts.InputDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !info.IsDir() && output.matches(path, info) {
+				opts.Logger.Debug("Output file excluded from inputs", "path", path)
+				return nil
+			}
+			if info.IsDir() || !scanned(path) {
+				return nil
+			}
+			fileType, ok := detect(path)
+			if !ok {
+				return nil
+			}
+			if fileType != format.InputType() {
+				// The extension named the type, e.g. a .txt file holding a PDF document
+				fail(path, contentMismatch(path, fileType, format.InputType()))
+				return nil
+			}
+			files = append(files, path)
+			opts.Progress.report(ProgressEvent{Stage: ProgressScanned, File: path, Current: len(files)})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error scanning directory: %w", err)
+		}
+	}
+
+	// Snippets included by other files of the scan are merged as part of them, not once more on their own
+	inputs := append(fileInputs(files), archived...)
+	if includes {
+		inputs = dropIncludedInputs(inputs, opts)
+	}
+
+	// Chapters of the summary keep its titles and nesting
+	if opts.Summary != "" {
+		entries, err := readSummary(opts.Summary)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if entry.Path == "" {
+				opts.Logger.Debug("Draft chapter without a file left out", "title", entry.Title)
+				continue
+			}
+			if fileType, ok := checkFile(entry.Path, false); ok {
+				inputs = append(inputs, Input{Path: entry.Path, Title: entry.Title, Level: entry.Level, fileType: fileType})
+				opts.Progress.report(ProgressEvent{Stage: ProgressScanned, File: entry.Path, Current: len(inputs)})
+			}
+		}
+	}
+
+	// Validate that each listed file exists and has the right type, listed archives are replaced by their files
+	for _, file := range opts.Files {
+		if in, ok := opts.FileInputs[file]; ok {
+			inputs = append(inputs, in)
+			continue
+		}
+		fileType, ok := checkFile(file, true)
+		if ok && fileType == archiveFileType {
+			inputs = append(inputs, archiveInputs(file)...)
+		} else if ok {
+			inputs = append(inputs, Input{Path: file, fileType: fileType})
+			opts.Progress.report(ProgressEvent{Stage: ProgressScanned, File: file, Current: len(inputs)})
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, skipped, err
+	}
+	if len(invalid) > 0 {
+		return nil, skipped, errors.Join(invalid...)
+	}
+
+	inputs = append(inputs, opts.Inputs...)
+	if len(inputs) == 0 {
+		if opts.Summary != "" && opts.InputDir == "" && len(opts.Files) == 0 && len(skipped) == 0 {
+			return nil, skipped, newError(ErrNoInputFiles, nil, "No %s files listed in %s", format.inputFormat().displayName(), opts.Summary)
+		}
+		if len(opts.Files) == 0 && opts.Summary == "" {
+			return nil, skipped, newError(ErrNoInputFiles, nil, "No %s files found in directory %s", format.inputFormat().displayName(), opts.InputDir)
+		}
+		return nil, skipped, newError(ErrNoValidFiles, nil, "No valid %s files to merge", format.inputFormat().displayName())
+	}
+
+	order := opts.Order
+	if order == "" {
+		order = SortNone
+		if opts.Summary == "" && len(opts.Files) == 0 && len(opts.Inputs) == 0 {
+			order = SortAlphanumeric
+		}
+	}
+	sortInputs(inputs, order)
+
+	return inputs, skipped, nil
+}
+
+// matchesPatterns reports whether the file name of path passes the include and exclude patterns of opts
+func matchesPatterns(path string, opts Options) bool {
+	name := filepath.Base(path)
+	if len(opts.Include) > 0 {
+		included := false
+		for _, pattern := range opts.Include {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, pattern := range opts.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sortInputs sorts inputs in place by name according to order
+func sortInputs(inputs []Input, order SortOrder) {
+	switch order {
+	case SortAlphanumeric:
+		sort.SliceStable(inputs, func(i, j int) bool {
+			return inputs[i].displayName() < inputs[j].displayName()
+		})
+	case SortNatural:
+		sort.SliceStable(inputs, func(i, j int) bool {
+			return naturalLess(inputs[i].displayName(), inputs[j].displayName())
+		})
+	}
+}
+
+// naturalLess compares two strings, treating runs of digits as numbers
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numA, restA := splitDigits(a)
+			numB, restB := splitDigits(b)
+			// Compare numeric values by length first, ignoring leading zeros
+			trimA, trimB := strings.TrimLeft(numA, "0"), strings.TrimLeft(numB, "0")
+			if len(trimA) != len(trimB) {
+				return len(trimA) < len(trimB)
+			}
+			if trimA != trimB {
+				return trimA < trimB
+			}
+			if numA != numB {
+				return len(numA) < len(numB)
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// splitDigits splits s into its leading run of digits and the remainder
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,45 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFileInputsMergedInPlace(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	if err := os.WriteFile(first, []byte("first\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	image := filepath.Join(dir, "image.png")
+	if err := os.WriteFile(image, []byte("\x89PNG\r\n\x1a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	archive := writeTestZip(t, map[string][]byte{"b.txt": []byte("b\n"), "a.txt": []byte("a\n")})
+
+	// The files around the stdin argument are checked, filtered and expanded like any listed file
+	var out bytes.Buffer
+	result, err := MergeContext(context.Background(), Options{
+		Files:      []string{first, "-", image, archive},
+		FileInputs: map[string]Input{"-": {Name: "stdin", Reader: strings.NewReader("stdin\n")}},
+		Output:     &out,
+		Format:     FormatText,
+	})
+	if err != nil {
+		t.Fatalf("MergeContext() error = %v", err)
+	}
+	if want := []string{first, "stdin", archive + "/a.txt", archive + "/b.txt"}; !slices.Equal(result.FilesList, want) {
+		t.Errorf("MergeContext() files = %v, want %v", result.FilesList, want)
+	}
+	if len(result.SkippedFiles) != 1 || result.SkippedFiles[0].Path != image {
+		t.Errorf("MergeContext() skipped = %v, want %s", result.SkippedFiles, image)
+	}
+	if got := out.String(); !strings.Contains(got, "first\n") || !strings.Contains(got, "stdin\n") {
+		t.Errorf("MergeContext() output = %q", got)
+	}
+}
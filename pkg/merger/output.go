@@ -0,0 +1,168 @@
+package merger
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExistingOutput selects what a merge does when the output file already exists
+type ExistingOutput string
+
+const (
+	// ExistingOverwrite replaces the existing output file, this is the default
+	ExistingOverwrite ExistingOutput = "overwrite"
+	// ExistingError fails the merge with ErrOutputExists
+	ExistingError ExistingOutput = "error"
+	// ExistingSkip leaves the existing output file untouched and returns without merging
+	ExistingSkip ExistingOutput = "skip"
+)
+
+// ParseExistingOutput parses an existing output mode name, an empty string selects ExistingOverwrite
+func ParseExistingOutput(s string) (ExistingOutput, error) {
+	switch mode := ExistingOutput(strings.ToLower(s)); mode {
+	case "", ExistingOverwrite:
+		return ExistingOverwrite, nil
+	case ExistingError, ExistingSkip:
+		return mode, nil
+	}
+	return "", newError(ErrInvalidOptions, nil, "Invalid existing output mode %q, expected overwrite, error or skip", s)
+}
+
+// tempFilePattern is the name pattern of the temporary file written next to the output file,
+// its extension keeps it out of directory scans
+const tempFilePattern = ".pdf-merger-*.tmp"
+
+// checkExistingOutput returns ErrOutputExists if path exists and mode does not allow overwriting it
+func checkExistingOutput(path string, mode ExistingOutput) error {
+	if mode == "" || mode == ExistingOverwrite {
+		return nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return newError(ErrOutputExists, nil, "Output file already exists: %s", path)
+	}
+	return nil
+}
+
+// atomicFile is an io.Writer writing to a temporary file in the directory of the output file.
+// commit renames it to the output path, so the output is either fully written or left untouched.
+type atomicFile struct {
+	path     string
+	existing ExistingOutput
+	temp     *os.File
+}
+
+// createAtomicFile creates the temporary file for the output path
+func createAtomicFile(path string, existing ExistingOutput) (*atomicFile, error) {
+	temp, err := os.CreateTemp(filepath.Dir(path), tempFilePattern)
+	if err != nil {
+		return nil, newError(ErrOutputFailed, err, "Failed to create output file: %v", err)
+	}
+	return &atomicFile{path: path, existing: existing, temp: temp}, nil
+}
+
+func (af *atomicFile) Write(p []byte) (int, error) {
+	n, err := af.temp.Write(p)
+	if err != nil {
+		return n, newError(ErrOutputFailed, err, "Failed to write output file: %v", err)
+	}
+	return n, nil
+}
+
+// commit moves the temporary file to the output path, keeping the permissions of a replaced file. The data
+// is flushed to disk first, so that a crash never leaves a truncated file under the output path.
+func (af *atomicFile) commit() error {
+	if err := af.temp.Sync(); err != nil {
+		af.abort()
+		return newError(ErrOutputFailed, err, "Failed to write output file: %v", err)
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(af.path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := af.temp.Chmod(mode); err != nil {
+		af.abort()
+		return newError(ErrOutputFailed, err, "Failed to set output file permissions: %v", err)
+	}
+	if err := af.temp.Close(); err != nil {
+		os.Remove(af.temp.Name())
+		return newError(ErrOutputFailed, err, "Failed to write output file: %v", err)
+	}
+
+	if af.existing == "" || af.existing == ExistingOverwrite {
+		if err := os.Rename(af.temp.Name(), af.path); err != nil {
+			os.Remove(af.temp.Name())
+			return newError(ErrOutputFailed, err, "Failed to move output file into place: %v", err)
+		}
+		syncDir(af.path)
+		return nil
+	}
+
+	// The output may have been created while merging. Linking fails if it exists, unlike a rename, so
+	// it is never replaced. Checking before the rename is only left for file systems without hard links.
+	defer os.Remove(af.temp.Name())
+	err := os.Link(af.temp.Name(), af.path)
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		return newError(ErrOutputExists, nil, "Output file already exists: %s", af.path)
+	case err != nil:
+		if err := checkExistingOutput(af.path, af.existing); err != nil {
+			return err
+		}
+		if err := os.Rename(af.temp.Name(), af.path); err != nil {
+			return newError(ErrOutputFailed, err, "Failed to move output file into place: %v", err)
+		}
+	}
+	syncDir(af.path)
+	return nil
+}
+
+// syncDir flushes the directory entry of path to disk where the system supports it
+func syncDir(path string) {
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+}
+
+// abort removes the temporary file, leaving any existing output file untouched
+func (af *atomicFile) abort() {
+	af.temp.Close()
+	os.Remove(af.temp.Name())
+}
+
+// outputMatcher reports whether a scanned file is the output file of the merge
+type outputMatcher struct {
+	path string
+	info os.FileInfo
+}
+
+// newOutputMatcher returns a matcher for outputFile, which may not exist yet
+func newOutputMatcher(outputFile string) outputMatcher {
+	if outputFile == "" {
+		return outputMatcher{}
+	}
+	m := outputMatcher{path: outputFile}
+	if abs, err := filepath.Abs(outputFile); err == nil {
+		m.path = abs
+	}
+	if info, err := os.Stat(outputFile); err == nil {
+		m.info = info
+	}
+	return m
+}
+
+// matches reports whether path, described by info, is the output file
+func (m outputMatcher) matches(path string, info os.FileInfo) bool {
+	if m.path == "" {
+		return false
+	}
+	if m.info != nil && os.SameFile(m.info, info) {
+		return true
+	}
+	abs, err := filepath.Abs(path)
+	return err == nil && abs == m.path
+}
@@ -0,0 +1,64 @@
+package merger
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFileCommit(t *testing.T) {
+	tests := []struct {
+		mode    ExistingOutput
+		want    string
+		wantErr error
+	}{
+		{ExistingOverwrite, "new", nil},
+		{ExistingError, "old", ErrOutputExists},
+		{ExistingSkip, "old", ErrOutputExists},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.pdf")
+		af, err := createAtomicFile(path, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := af.Write([]byte("new")); err != nil {
+			t.Fatal(err)
+		}
+		// The output file appears while merging
+		if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		err = af.commit()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("commit() with %s error = %v, want %v", tt.mode, err, tt.wantErr)
+		}
+		if data, _ := os.ReadFile(path); string(data) != tt.want {
+			t.Errorf("commit() with %s left %q, want %q", tt.mode, data, tt.want)
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+			t.Errorf("commit() with %s changed the permissions to %v", tt.mode, info.Mode().Perm())
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("commit() with %s left %d files, want only the output file", tt.mode, len(entries))
+		}
+	}
+}
+
+func TestAtomicFileCommitCreates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.pdf")
+	af, err := createAtomicFile(path, ExistingError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	af.Write([]byte("new"))
+	if err := af.commit(); err != nil {
+		t.Fatalf("commit() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("commit() wrote %q, want %q", data, "new")
+	}
+}
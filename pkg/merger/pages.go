@@ -0,0 +1,421 @@
+package merger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PagesResult stores the result of a page edit
+type PagesResult struct {
+	Success      bool   `json:"success"`
+	OutputPath   string `json:"outputPath,omitempty"`
+	PageCount    int    `json:"pageCount,omitempty"` // Number of pages of the edited document
+	Pages        []int  `json:"pages,omitempty"`     // Pages removed, moved or inserted, numbered as in their original document
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+// ParsePageRanges parses a page range expression for a document with pageCount pages and returns the selected
+// page numbers in the given order, without duplicates. The expression is a comma-separated list of pages (5)
+// and ranges (1-3); ranges can be open (8- or -3) and "last" selects the last page, e.g. "1-3,5,last".
+func ParsePageRanges(expr string, pageCount int) ([]int, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, newError(ErrInvalidOptions, nil, "No pages specified")
+	}
+
+	page := func(s string, empty int) (int, error) {
+		switch s = strings.TrimSpace(s); s {
+		case "":
+			return empty, nil
+		case "last", "l":
+			return pageCount, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, newError(ErrInvalidOptions, err, "Invalid page number %q in page range %q", s, expr)
+		}
+		if n > pageCount {
+			return 0, newError(ErrInvalidOptions, nil, "Page %d is out of range, the document has %d pages", n, pageCount)
+		}
+		return n, nil
+	}
+
+	var pages []int
+	for _, part := range strings.Split(expr, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := page(from, 1)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = page(to, pageCount); err != nil {
+				return nil, err
+			}
+		} else if strings.TrimSpace(from) == "" {
+			return nil, newError(ErrInvalidOptions, nil, "Empty entry in page range %q", expr)
+		}
+		if first > last {
+			return nil, newError(ErrInvalidOptions, nil, "Invalid page range %q, %d is after %d", strings.TrimSpace(part), first, last)
+		}
+		for p := first; p <= last; p++ {
+			if !slices.Contains(pages, p) {
+				pages = append(pages, p)
+			}
+		}
+	}
+	return pages, nil
+}
+
+// RemovePages removes the pages selected by the page range expression from the PDF file at path and writes
+// the result to output, which can be path itself. Bookmarks and links to removed pages are moved to the next page.
+func RemovePages(path, output, ranges string) (*PagesResult, error) {
+	return editPages(path, output, model.REMOVEPAGES, func(ctx *model.Context) ([]int, error) {
+		removed, err := ParsePageRanges(ranges, ctx.PageCount)
+		if err != nil {
+			return nil, err
+		}
+		if len(removed) == ctx.PageCount {
+			return nil, newError(ErrInvalidOptions, nil, "Cannot remove every page of %s", path)
+		}
+
+		var kept []int
+		for p := 1; p <= ctx.PageCount; p++ {
+			if !slices.Contains(removed, p) {
+				kept = append(kept, p)
+			}
+		}
+		slices.Sort(removed)
+		return removed, selectPages(ctx, kept)
+	})
+}
+
+// MovePages moves the pages selected by the page range expression of the PDF file at path after page after,
+// or to the beginning when after is 0, and writes the result to output, which can be path itself.
+// The moved pages keep the order of the expression.
+func MovePages(path, output, ranges string, after int) (*PagesResult, error) {
+	return editPages(path, output, model.MERGECREATE, func(ctx *model.Context) ([]int, error) {
+		moved, err := ParsePageRanges(ranges, ctx.PageCount)
+		if err != nil {
+			return nil, err
+		}
+		if after < 0 || after > ctx.PageCount {
+			return nil, newError(ErrInvalidOptions, nil, "Page %d is out of range, the document has %d pages", after, ctx.PageCount)
+		}
+		if slices.Contains(moved, after) {
+			return nil, newError(ErrInvalidOptions, nil, "Cannot move pages after page %d, it is one of the moved pages", after)
+		}
+
+		var order []int
+		if after == 0 {
+			order = append(order, moved...)
+		}
+		for p := 1; p <= ctx.PageCount; p++ {
+			if slices.Contains(moved, p) {
+				continue
+			}
+			order = append(order, p)
+			if p == after {
+				order = append(order, moved...)
+			}
+		}
+		return moved, selectPages(ctx, order)
+	})
+}
+
+// InsertPages inserts pages of the PDF file source into the PDF file at path after page after, or at the
+// beginning when after is 0, and writes the result to output, which can be path itself. ranges selects the
+// pages of source to insert, in order; all pages are inserted when it is empty. If the document has bookmarks,
+// one is added for the inserted pages.
+func InsertPages(path, output, source, ranges string, after int) (*PagesResult, error) {
+	return editPages(path, output, model.MERGEAPPEND, func(dest *model.Context) ([]int, error) {
+		if after < 0 || after > dest.PageCount {
+			return nil, newError(ErrInvalidOptions, nil, "Page %d is out of range, the document has %d pages", after, dest.PageCount)
+		}
+
+		src, err := readPDFFile(context.Background(), source, dest.Configuration.Cmd)
+		if err != nil {
+			return nil, err
+		}
+		inserted := make([]int, src.PageCount)
+		for i := range inserted {
+			inserted[i] = i + 1
+		}
+		if ranges != "" {
+			if inserted, err = ParsePageRanges(ranges, src.PageCount); err != nil {
+				return nil, err
+			}
+			if err := selectPages(src, inserted); err != nil {
+				return nil, err
+			}
+		}
+
+		if dest.XRefTable.Version() < model.V20 && src.XRefTable.Version() == model.V20 {
+			return nil, pdfcpu.ErrUnsupportedVersion
+		}
+		if dest.XRefTable.Version() < model.V20 {
+			dest.EnsureVersionForWriting()
+		}
+
+		// Only add a bookmark for the inserted pages to documents that have bookmarks
+		hasOutlines, err := hasBookmarks(dest)
+		if err != nil {
+			return nil, err
+		}
+		dest.Configuration.CreateBookmarks = hasOutlines
+
+		// Pages inserted after the last page are appended to the page tree
+		before := after + 1
+		if after == dest.PageCount {
+			before = 0
+		}
+		if err := pdfcpu.MergeXRefTables(filepath.Base(source), src, dest, false, false); err != nil {
+			return nil, err
+		}
+		if err := relinkAppendedPages(dest, before); err != nil {
+			return nil, err
+		}
+		if hasOutlines && before > 0 {
+			if err := moveLastBookmark(dest, before); err != nil {
+				return nil, err
+			}
+		}
+		return inserted, nil
+	})
+}
+
+// editPages reads the PDF file at path, applies edit to it and writes the result to output through a temporary file.
+// edit returns the pages it changed, for the result.
+func editPages(path, output string, cmd model.CommandMode, edit func(ctx *model.Context) ([]int, error)) (*PagesResult, error) {
+	fail := func(err error) (*PagesResult, error) {
+		return &PagesResult{Success: false, ErrorMessage: err.Error()}, err
+	}
+	if output == "" {
+		return fail(newError(ErrInvalidOptions, nil, "No output file specified"))
+	}
+	ctx, err := readPDFFile(context.Background(), path, cmd)
+	if err != nil {
+		return fail(err)
+	}
+
+	pages, err := edit(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := writePDFFile(ctx, output); err != nil {
+		return fail(err)
+	}
+
+	return &PagesResult{
+		Success:    true,
+		OutputPath: output,
+		PageCount:  ctx.PageCount,
+		Pages:      pages,
+	}, nil
+}
+
+// readPDFFile reads the PDF file at path in relaxed validation mode, for the pdfcpu command cmd
+func readPDFFile(ctx context.Context, path string, cmd model.CommandMode) (*model.Context, error) {
+	if fileTypeForPath(path) != "pdf" {
+		return nil, newError(ErrUnsupportedFileType, nil, "Not a PDF file: %s", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, newError(ErrInputNotFound, err, "File does not exist: %s", path)
+		}
+		return nil, fmt.Errorf("Cannot access %s: %w", path, err)
+	}
+
+	conf := validationConfig(ValidationRelaxed)
+	conf.Cmd = cmd
+	pdf, err := readPDFInput(ctx, Input{Path: path}, conf)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &InvalidFileError{Path: path, Reason: cleanPDFError(err)}
+	}
+	return pdf, nil
+}
+
+// readPDFDocument reads a PDF input like readPDFFile, inputs with a reader are read from it
+func readPDFDocument(ctx context.Context, in Input, cmd model.CommandMode) (*model.Context, error) {
+	if in.Reader == nil {
+		return readPDFFile(ctx, in.Path, cmd)
+	}
+
+	conf := validationConfig(ValidationRelaxed)
+	conf.Cmd = cmd
+	pdf, err := readPDFInput(ctx, in, conf)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, &InvalidFileError{Path: in.displayName(), Reason: cleanPDFError(err)}
+	}
+	return pdf, nil
+}
+
+// writePDFFile writes pdf to path through a temporary file, replacing an existing file once it is complete
+func writePDFFile(pdf *model.Context, path string) error {
+	file, err := createAtomicFile(path, ExistingOverwrite)
+	if err != nil {
+		return err
+	}
+	if err := api.WriteContext(pdf, file); err != nil {
+		file.abort()
+		return newError(ErrOutputFailed, err, "Failed to write %s: %v", path, err)
+	}
+	return file.commit()
+}
+
+// selectPages replaces the page tree of ctx with a single node listing the given pages in order.
+// Destinations pointing to pages that are left out are moved to the next page that is kept, or the last one.
+func selectPages(ctx *model.Context, pages []int) error {
+	refs := make([]types.IndirectRef, ctx.PageCount+1)
+	for p := 1; p <= ctx.PageCount; p++ {
+		d, ref, inherited, err := ctx.PageDict(p, false)
+		if err != nil {
+			return err
+		}
+		// Attributes inherited from intermediate nodes are lost with them, so each page gets its own copy
+		if _, ok := d.Find("Resources"); !ok && inherited.Resources != nil {
+			d["Resources"] = inherited.Resources
+		}
+		if _, ok := d.Find("MediaBox"); !ok && inherited.MediaBox != nil {
+			d["MediaBox"] = inherited.MediaBox.Array()
+		}
+		if _, ok := d.Find("CropBox"); !ok && inherited.CropBox != nil {
+			d["CropBox"] = inherited.CropBox.Array()
+		}
+		if _, ok := d.Find("Rotate"); !ok && inherited.Rotate != 0 {
+			d["Rotate"] = types.Integer(inherited.Rotate)
+		}
+		refs[p] = *ref
+	}
+
+	// Map every page that is left out to the page its destinations move to
+	replacements := map[int]types.IndirectRef{}
+	for p := 1; p <= ctx.PageCount; p++ {
+		if slices.Contains(pages, p) {
+			continue
+		}
+		target := slices.Max(pages)
+		for next := p + 1; next <= ctx.PageCount; next++ {
+			if slices.Contains(pages, next) {
+				target = next
+				break
+			}
+		}
+		replacements[refs[p].ObjectNumber.Value()] = refs[target]
+	}
+	if len(replacements) > 0 {
+		for _, entry := range ctx.Table {
+			if !entry.Free && entry.Object != nil {
+				retargetDestinations(entry.Object, replacements)
+			}
+		}
+
+		// The structure tree of a tagged PDF refers to every page, it would keep the left out pages in the document
+		catalog, err := ctx.Catalog()
+		if err != nil {
+			return err
+		}
+		catalog.Delete("StructTreeRoot")
+		catalog.Delete("MarkInfo")
+	}
+
+	rootRef, err := ctx.Pages()
+	if err != nil {
+		return err
+	}
+	root, err := ctx.DereferenceDict(*rootRef)
+	if err != nil {
+		return err
+	}
+	kids := make(types.Array, len(pages))
+	for i, p := range pages {
+		kids[i] = refs[p]
+		page, err := ctx.DereferenceDict(refs[p])
+		if err != nil {
+			return err
+		}
+		page["Parent"] = *rootRef
+	}
+	root["Kids"] = kids
+	root["Count"] = types.Integer(len(pages))
+	ctx.PageCount = len(pages)
+	return nil
+}
+
+// retargetDestinations replaces, in o and the direct objects it contains, the page of every destination array
+// pointing to a page in replacements
+func retargetDestinations(o types.Object, replacements map[int]types.IndirectRef) {
+	switch o := o.(type) {
+	case types.Dict:
+		for _, v := range o {
+			retargetDestinations(v, replacements)
+		}
+	case types.StreamDict:
+		retargetDestinations(o.Dict, replacements)
+	case types.Array:
+		// A destination is an array starting with the page followed by the view, e.g. [3 0 R /Fit]
+		if len(o) >= 2 {
+			if ref, ok := o[0].(types.IndirectRef); ok {
+				if _, ok := o[1].(types.Name); ok {
+					if target, ok := replacements[ref.ObjectNumber.Value()]; ok {
+						o[0] = target
+					}
+				}
+			}
+		}
+		for _, v := range o {
+			retargetDestinations(v, replacements)
+		}
+	}
+}
+
+// hasBookmarks reports whether ctx has a non-empty outline
+func hasBookmarks(ctx *model.Context) (bool, error) {
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		return false, err
+	}
+	obj, ok := catalog.Find("Outlines")
+	if !ok {
+		return false, nil
+	}
+	outlines, err := ctx.DereferenceDict(obj)
+	if err != nil {
+		return false, err
+	}
+	return outlines != nil && outlines.IndirectRefEntry("First") != nil, nil
+}
+
+// FormatPageRanges returns pages as a compact page range expression, e.g. "1-3,7"
+func FormatPageRanges(pages []int) string {
+	var parts []string
+	for i := 0; i < len(pages); {
+		j := i
+		for j+1 < len(pages) && pages[j+1] == pages[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", pages[i], pages[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(pages[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
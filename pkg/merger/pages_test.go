@@ -0,0 +1,78 @@
+package merger
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParsePageRanges(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []int
+	}{
+		{"1-3,5,last", []int{1, 2, 3, 5, 10}},
+		{"8-", []int{8, 9, 10}},
+		{"-3", []int{1, 2, 3}},
+		{"l", []int{10}},
+		{"last-last", []int{10}},
+		{" 2 - 4 ", []int{2, 3, 4}},
+		{"5,1-2", []int{5, 1, 2}},
+		{"1-4,3-6", []int{1, 2, 3, 4, 5, 6}},
+		{"3,3,1-3", []int{3, 1, 2}},
+		{"-", []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+		{"10", []int{10}},
+		{"-1", []int{1}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePageRanges(tt.expr, 10)
+		if err != nil {
+			t.Errorf("ParsePageRanges(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParsePageRanges(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParsePageRangesErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		desc string
+	}{
+		{"", "empty expression"},
+		{"  ", "blank expression"},
+		{"5-3", "reversed range"},
+		{"last-2", "reversed range ending before the last page"},
+		{"11", "page out of range"},
+		{"9-12", "range past the last page"},
+		{"0", "page zero"},
+		{"1,,2", "empty entry"},
+		{"abc", "not a number"},
+		{"1-2-3", "range with two dashes"},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePageRanges(tt.expr, 10); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("ParsePageRanges(%q) with %s error = %v, want invalid options", tt.expr, tt.desc, err)
+		}
+	}
+}
+
+func TestFormatPageRanges(t *testing.T) {
+	tests := []struct {
+		pages []int
+		want  string
+	}{
+		{nil, ""},
+		{[]int{4}, "4"},
+		{[]int{1, 2, 3, 7}, "1-3,7"},
+		{[]int{5, 1, 2}, "5,1-2"},
+		{[]int{1, 3, 5}, "1,3,5"},
+	}
+	for _, tt := range tests {
+		if got := FormatPageRanges(tt.pages); got != tt.want {
+			t.Errorf("FormatPageRanges(%v) = %q, want %q", tt.pages, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package merger
+
+import "io"
+
+// ProgressStage identifies the step of a merge reported by a ProgressEvent
+type ProgressStage string
+
+const (
+	// ProgressScanned is reported for every input file found, Total is not known yet
+	ProgressScanned ProgressStage = "scanned"
+	// ProgressValidated is reported after each PDF file has been validated, whether it passed or not
+	ProgressValidated ProgressStage = "validated"
+	// ProgressMerged is reported after the pages of each file have been added to the output
+	ProgressMerged ProgressStage = "merged"
+	// ProgressWritten is reported while the output file is written
+	ProgressWritten ProgressStage = "written"
+)
+
+// ProgressEvent describes the progress of a merge
+type ProgressEvent struct {
+	Stage        ProgressStage `json:"stage"`
+	File         string        `json:"file,omitempty"`         // File the event refers to, empty for ProgressWritten
+	Current      int           `json:"current"`                // Number of files done in this stage
+	Total        int           `json:"total"`                  // Number of files in this stage, 0 if not known yet
+	Pages        int           `json:"pages,omitempty"`        // Number of pages merged so far (PDF only)
+	BytesWritten int64         `json:"bytesWritten,omitempty"` // Number of bytes written to the output so far
+}
+
+// ProgressFunc receives progress events. It is called synchronously from the goroutine running the merge,
+// so it should return quickly.
+type ProgressFunc func(ProgressEvent)
+
+// report calls f with event, if f is set
+func (f ProgressFunc) report(event ProgressEvent) {
+	if f != nil {
+		f(event)
+	}
+}
+
+// progressWriter is an io.Writer that reports the number of bytes written as ProgressWritten events
+type progressWriter struct {
+	w        io.Writer
+	progress ProgressFunc
+	written  int64
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	pw.progress.report(ProgressEvent{Stage: ProgressWritten, BytesWritten: pw.written})
+	return n, err
+}
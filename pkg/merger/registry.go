@@ -0,0 +1,362 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+// FileType describes a kind of input file: how it is recognized and how it is converted for formats that
+// merge another type of document
+type FileType struct {
+	Name        string   // Identifier used in results and the API, e.g. "pdf"
+	DisplayName string   // Name used in messages, e.g. "PDF"
+	Extensions  []string // File name extensions in lower case, with the dot
+	// Detect reports whether content starting with the given bytes has the signature of this type,
+	// nil for types without one
+	Detect func(head []byte) bool
+	Text   bool // Content is text, told apart from other text types by the extension
+	// Convert turns an input into a document of another file type, by the name of that type. An output
+	// format accepts listed files of any type that converts to the type it merges.
+	Convert map[string]ConvertFunc
+}
+
+// ConvertFunc converts an input into a document of another file type
+type ConvertFunc func(ctx context.Context, in Input) (Input, error)
+
+// MergeFunc merges inputs into a document written to w
+type MergeFunc func(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error)
+
+// FormatSpec describes an output format: its file names, the type of files merged into it and how
+type FormatSpec struct {
+	Format      Format
+	DisplayName string   // Name used in messages, e.g. "EPUB"
+	Aliases     []string // Other names accepted by ParseFormat
+	Extensions  []string // Output file name extensions in lower case, with the dot, the first is the usual one
+	InputType   string   // File type merged, the only type picked up when scanning input directories
+	MediaType   string   // Content type of the output, e.g. "application/pdf"
+	// ScanOutputExtension restricts directory scans to files with the extension of the output file,
+	// when it has one of Extensions, e.g. to merge only the .sql files of a directory into a .sql file
+	ScanOutputExtension bool
+	Merge               MergeFunc
+}
+
+// registry holds the registered file types and formats, in registration order
+var registry = struct {
+	sync.RWMutex
+	types   []FileType
+	formats []FormatSpec
+}{}
+
+// RegisterFileType adds a file type, replacing a registered type with the same name
+func RegisterFileType(t FileType) {
+	registry.Lock()
+	defer registry.Unlock()
+	if i := slices.IndexFunc(registry.types, func(r FileType) bool { return r.Name == t.Name }); i >= 0 {
+		registry.types[i] = t
+		return
+	}
+	registry.types = append(registry.types, t)
+}
+
+// RegisterFormat adds an output format, replacing a registered format with the same name
+func RegisterFormat(f FormatSpec) {
+	registry.Lock()
+	defer registry.Unlock()
+	if i := slices.IndexFunc(registry.formats, func(r FormatSpec) bool { return r.Format == f.Format }); i >= 0 {
+		registry.formats[i] = f
+		return
+	}
+	registry.formats = append(registry.formats, f)
+}
+
+// FileTypes returns the registered file types
+func FileTypes() []FileType {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Clone(registry.types)
+}
+
+// Formats returns the registered output formats
+func Formats() []FormatSpec {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Clone(registry.formats)
+}
+
+// lookupFileType returns the registered file type called name
+func lookupFileType(name string) (FileType, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, t := range registry.types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return FileType{}, false
+}
+
+// lookupFormat returns the registered format f
+func lookupFormat(f Format) (FormatSpec, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, spec := range registry.formats {
+		if spec.Format == f {
+			return spec, true
+		}
+	}
+	return FormatSpec{}, false
+}
+
+// FileTypeForPath returns the name of the registered file type matching the extension of path,
+// or an empty string. Extensions with several dots such as .tar.gz are matched as a whole.
+func FileTypeForPath(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, t := range registry.types {
+		for _, ext := range t.Extensions {
+			if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+				return t.Name
+			}
+		}
+	}
+	return ""
+}
+
+// FormatForPath returns the registered format written to files with the extension of path, or an empty Format
+func FormatForPath(path string) Format {
+	ext := strings.ToLower(filepath.Ext(path))
+	registry.RLock()
+	defer registry.RUnlock()
+	for _, spec := range registry.formats {
+		if slices.Contains(spec.Extensions, ext) {
+			return spec.Format
+		}
+	}
+	return ""
+}
+
+// FormatForFileType returns the first registered format that merges files of the named type, or else the first
+// format they can be converted for, or an empty Format
+func FormatForFileType(fileType string) Format {
+	formats := Formats()
+	for _, spec := range formats {
+		if spec.InputType == fileType {
+			return spec.Format
+		}
+	}
+	for _, spec := range formats {
+		if spec.Format.Accepts(fileType) {
+			return spec.Format
+		}
+	}
+	return ""
+}
+
+// scanExtension returns the only extension picked up when scanning directories for format f and the
+// output file outputFile, or an empty string
+func (f Format) scanExtension(outputFile string) string {
+	spec, _ := lookupFormat(f)
+	ext := strings.ToLower(filepath.Ext(outputFile))
+	if spec.ScanOutputExtension && slices.Contains(spec.Extensions, ext) {
+		return ext
+	}
+	return ""
+}
+
+// InputType returns the type of the files merged into documents of format f
+func (f Format) InputType() string {
+	spec, _ := lookupFormat(f)
+	return spec.InputType
+}
+
+// MediaType returns the content type of documents of format f, application/octet-stream when unknown
+func (f Format) MediaType() string {
+	if spec, ok := lookupFormat(f); ok && spec.MediaType != "" {
+		return spec.MediaType
+	}
+	return "application/octet-stream"
+}
+
+// Accepts reports whether files of the named type can be merged into documents of format f,
+// as they are or converted
+func (f Format) Accepts(fileType string) bool {
+	spec, ok := lookupFormat(f)
+	if !ok || fileType == "" {
+		return false
+	}
+	if fileType == spec.InputType {
+		return true
+	}
+	t, ok := lookupFileType(fileType)
+	return ok && t.Convert[spec.InputType] != nil
+}
+
+// acceptedTypes returns the display names of the file types accepted by format f, e.g. "PDF or image"
+func (f Format) acceptedTypes() string {
+	var names []string
+	for _, t := range FileTypes() {
+		if f.Accepts(t.Name) {
+			names = append(names, t.DisplayName)
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+// convertInputs converts the inputs whose file type, detected or from their name, differs from the type merged into format
+func convertInputs(ctx context.Context, inputs []Input, format Format) ([]Input, error) {
+	target := format.InputType()
+	converted := make([]Input, len(inputs))
+	for i, in := range inputs {
+		converted[i] = in
+		name := in.fileType
+		if name == "" {
+			name = FileTypeForPath(in.displayName())
+		}
+		if name == "" || name == target {
+			continue
+		}
+		t, _ := lookupFileType(name)
+		convert := t.Convert[target]
+		if convert == nil {
+			return nil, newError(ErrUnsupportedFileType, nil, "Cannot merge %s file %s into %s", t.DisplayName, in.displayName(), format.displayName())
+		}
+		out, err := convert(ctx, in)
+		if err != nil {
+			return nil, &InvalidFileError{Path: in.displayName(), Reason: fmt.Sprintf("cannot convert to %s: %v", format.InputType(), err)}
+		}
+		converted[i] = out
+	}
+	return converted, nil
+}
+
+// imageToPDF converts an image into a PDF document with one A4 page showing it
+func imageToPDF(ctx context.Context, in Input) (Input, error) {
+	rs, release, err := in.open()
+	if err != nil {
+		return in, err
+	}
+	defer release()
+
+	var buf bytes.Buffer
+	if err := api.ImportImages(nil, &buf, []io.Reader{rs}, nil, nil); err != nil {
+		return in, fmt.Errorf("%s", cleanPDFError(err))
+	}
+	return Input{Name: in.displayName(), Reader: bytes.NewReader(buf.Bytes()), Title: in.Title, Level: in.Level}, nil
+}
+
+// keepInput merges an input as it is, for file types whose content is valid in the target type
+func keepInput(ctx context.Context, in Input) (Input, error) {
+	return in, nil
+}
+
+func init() {
+	RegisterFileType(FileType{Name: "pdf", DisplayName: "PDF", Extensions: []string{".pdf"}, Detect: isPDF})
+	RegisterFileType(FileType{Name: "markdown", DisplayName: "Markdown", Extensions: []string{".md", ".markdown"}, Text: true})
+	RegisterFileType(FileType{
+		Name:        textFileType,
+		DisplayName: "text",
+		Extensions:  []string{".txt", ".log"},
+		Text:        true,
+		Convert:     map[string]ConvertFunc{"markdown": keepInput},
+	})
+	RegisterFileType(FileType{
+		Name:        "csv",
+		DisplayName: "CSV",
+		Extensions:  []string{".csv"},
+		Text:        true,
+		Convert:     map[string]ConvertFunc{textFileType: keepInput},
+	})
+	RegisterFileType(FileType{
+		Name:        "code",
+		DisplayName: "source code",
+		Extensions:  codeExtensions(),
+		Text:        true,
+		Convert:     map[string]ConvertFunc{textFileType: keepInput},
+	})
+	RegisterFileType(FileType{
+		Name:        "image",
+		DisplayName: "image",
+		Extensions:  []string{".png", ".jpg", ".jpeg", ".tif", ".tiff", ".webp"},
+		Detect:      isImage,
+		Convert:     map[string]ConvertFunc{"pdf": imageToPDF},
+	})
+	RegisterFileType(FileType{
+		Name:        archiveFileType,
+		DisplayName: "archive",
+		Extensions:  []string{".zip", ".tar", ".tar.gz", ".tgz"},
+		Detect:      isArchive,
+	})
+
+	RegisterFormat(FormatSpec{
+		Format:      FormatPDF,
+		DisplayName: "PDF",
+		Extensions:  []string{".pdf"},
+		InputType:   "pdf",
+		MediaType:   "application/pdf",
+		Merge: func(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+			return mergeValidatedPDFs(ctx, inputs, opts.appendTo, w, opts)
+		},
+	})
+	RegisterFormat(FormatSpec{
+		Format:      FormatMarkdown,
+		DisplayName: "Markdown",
+		Aliases:     []string{"md"},
+		Extensions:  []string{".md", ".markdown"},
+		InputType:   "markdown",
+		MediaType:   "text/markdown; charset=utf-8",
+		Merge:       mergeMarkdown,
+	})
+	RegisterFormat(FormatSpec{
+		Format:      FormatHTML,
+		DisplayName: "HTML",
+		Aliases:     []string{"htm"},
+		Extensions:  []string{".html", ".htm"},
+		InputType:   "markdown",
+		MediaType:   "text/html; charset=utf-8",
+		Merge:       mergeHTML,
+	})
+	RegisterFormat(FormatSpec{
+		Format:      FormatEPUB,
+		DisplayName: "EPUB",
+		Extensions:  []string{".epub"},
+		InputType:   "markdown",
+		MediaType:   "application/epub+zip",
+		Merge:       mergeEPUB,
+	})
+	RegisterFormat(FormatSpec{
+		Format:      FormatText,
+		DisplayName: "text",
+		Aliases:     []string{"txt"},
+		Extensions:  []string{".txt", ".log"},
+		InputType:   textFileType,
+		MediaType:   "text/plain; charset=utf-8",
+		Merge:       mergeText,
+	})
+	RegisterFormat(FormatSpec{
+		Format:      FormatCSV,
+		DisplayName: "CSV",
+		Extensions:  []string{".csv"},
+		InputType:   "csv",
+		MediaType:   "text/csv; charset=utf-8",
+		Merge:       mergeCSV,
+	})
+	RegisterFormat(FormatSpec{
+		Format:      FormatCode,
+		DisplayName: "source code",
+		Extensions:  codeExtensions(),
+		InputType:   "code",
+		MediaType:   "text/plain; charset=utf-8",
+		Merge:       mergeCode,
+
+		ScanOutputExtension: true,
+	})
+}
@@ -0,0 +1,141 @@
+package merger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sniffLength is the number of leading bytes read to detect the type of a file. PDF readers accept junk
+// before the %PDF- header within the first 1024 bytes.
+const sniffLength = 1024
+
+// textFileType is the type of text content whose extension names no text file type
+const textFileType = "text"
+
+// SniffFileType returns the registered file type of content starting with head: the type whose signature
+// it has or, for text content, the text type named by the extension of name, plain text otherwise.
+// Content that contradicts the type named by the extension is reported as an *InvalidFileError, binary
+// content of an unknown type without a known extension yields an empty string.
+func SniffFileType(head []byte, name string) (string, error) {
+	extType := FileTypeForPath(name)
+	ext, _ := lookupFileType(extType)
+
+	detected := ""
+	for _, t := range FileTypes() {
+		if t.Detect != nil && t.Detect(head) {
+			detected = t.Name
+			break
+		}
+	}
+	if detected == "" && looksLikeText(head) {
+		detected = textFileType
+		if ext.Text {
+			detected = extType
+		}
+	}
+
+	switch {
+	case extType == "" || detected == extType:
+		return detected, nil
+	case ext.Text && detected != "" && detected != textFileType && signatureAtStart(detected, head):
+		// A document in a known binary format is what it is, whatever the name, e.g. report.PDF.txt
+		return detected, nil
+	}
+	return "", contentMismatch(name, detected, extType)
+}
+
+// contentMismatch returns the *InvalidFileError of a file named name whose content has the detected type
+// instead of the type named by its extension
+func contentMismatch(name, detected, extType string) error {
+	ext, _ := lookupFileType(extType)
+	return &InvalidFileError{Path: name, Reason: fmt.Sprintf("content is %s, not %s as the extension says", describeContent(detected), ext.DisplayName)}
+}
+
+// signatureAtStart reports whether the signature of the detected type is at the very start of head. A PDF
+// header may follow some binary junk, which is not enough to override a text extension.
+func signatureAtStart(fileType string, head []byte) bool {
+	return fileType != "pdf" || bytes.HasPrefix(head, []byte("%PDF-"))
+}
+
+// DetectFileType reads the start of the file at path and returns its type as SniffFileType does
+func DetectFileType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return DetectReaderType(f, path)
+}
+
+// DetectReaderType reads the start of rs and returns the type of the content named name as SniffFileType
+// does. rs is positioned at the start again when it returns.
+func DetectReaderType(rs io.ReadSeeker, name string) (string, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(rs, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return SniffFileType(head[:n], name)
+}
+
+// describeContent describes detected content in mismatch messages
+func describeContent(fileType string) string {
+	switch fileType {
+	case "":
+		return "binary data of an unknown type"
+	case textFileType:
+		return "text"
+	}
+	t, _ := lookupFileType(fileType)
+	return "a " + t.DisplayName + " file"
+}
+
+// looksLikeText reports whether head is the start of text: UTF-16 text, or content without NUL bytes and
+// with few control characters, which covers UTF-8 and legacy encodings such as GBK and Windows-1252
+func looksLikeText(head []byte) bool {
+	if _, name := detectEncoding(head); strings.HasPrefix(name, "utf-16") {
+		return true
+	}
+	var control int
+	for _, c := range head {
+		if c == 0 {
+			return false
+		}
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1b {
+			control++
+		}
+	}
+	return control*100 <= len(head)
+}
+
+// isPDF reports whether head starts with the %PDF- header, or has it after leading junk that is not text.
+// Text mentioning %PDF- is not a PDF file, and an archive storing a PDF uncompressed near its start is still
+// an archive.
+func isPDF(head []byte) bool {
+	i := bytes.Index(head, []byte("%PDF-"))
+	switch {
+	case i < 0 || isArchive(head):
+		return false
+	case i == 0:
+		return true
+	}
+	return !looksLikeText(head[:i])
+}
+
+// isImage reports whether head starts with the signature of a PNG, JPEG, TIFF or WebP image
+func isImage(head []byte) bool {
+	switch {
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")),
+		bytes.HasPrefix(head, []byte("\xff\xd8\xff")),
+		bytes.HasPrefix(head, []byte("II*\x00")),
+		bytes.HasPrefix(head, []byte("MM\x00*")):
+		return true
+	}
+	return len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP"))
+}
@@ -0,0 +1,36 @@
+package merger
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSniffFileType(t *testing.T) {
+	tests := []struct {
+		name    string
+		head    string
+		want    string
+		invalid bool
+	}{
+		{"notes.md", "# PDF notes\n\nEvery file starts with %PDF-1.7 and ends with %%EOF.\n", "markdown", false},
+		{"pdf-notes.md", "%PDF-1.7 is the header of every PDF file\n", "pdf", false},
+		{"access.log", "GET /report.pdf 200 %PDF-1.4\n", "text", false},
+		{"report.PDF.txt", "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n", "pdf", false},
+		{"junk.txt", "\x00\x01\x02%PDF-1.4\n", "", true},
+		{"report.pdf", "\x00\x01\x02%PDF-1.4\n", "pdf", false},
+		{"report.pdf", "<html>%PDF-1.4</html>", "", true},
+		{"report", "%PDF-1.4\n", "pdf", false},
+		{"readme.md", "# Title\n", "markdown", false},
+	}
+	for _, tt := range tests {
+		got, err := SniffFileType([]byte(tt.head), tt.name)
+		var invalidErr *InvalidFileError
+		if tt.invalid != errors.As(err, &invalidErr) {
+			t.Errorf("SniffFileType(%q, %q) error = %v, want invalid %v", tt.head, tt.name, err, tt.invalid)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("SniffFileType(%q, %q) = %q, want %q", tt.head, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package merger
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// summaryFileName is the usual name of an mdBook or GitBook summary, left out of Markdown directory scans
+const summaryFileName = "SUMMARY.md"
+
+var (
+	// summaryItem matches a list item of a summary file, capturing its indentation and text
+	summaryItem = regexp.MustCompile(`^([ \t]*)(?:[-*+]|\d+[.)])[ \t]+(.*)$`)
+	// summaryLink matches a link at the start of a summary entry: [Title](path) or [Title](<path>)
+	summaryLink = regexp.MustCompile(`^\[((?:[^\[\]]|\[[^\]]*\])*)\]\(\s*(<[^>]*>|[^\s)]*)(?:\s+"[^"]*")?\s*\)`)
+)
+
+// summaryEntry is a chapter listed in a summary file
+type summaryEntry struct {
+	Title string
+	Path  string // Empty for draft chapters, which have no file yet
+	Level int    // Nesting depth, 1 for top level chapters
+}
+
+// readSummary reads an mdBook or GitBook style SUMMARY.md file: a list of links to the chapters in reading
+// order, nested lists holding sub-chapters. Links outside lists, such as mdBook prefix chapters, are top
+// level chapters. Headings, separators and other text are ignored, and paths are resolved relative to the
+// summary file.
+func readSummary(path string) ([]summaryEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, newError(ErrInputNotFound, err, "Summary file not found: %s", path)
+		}
+		return nil, newError(ErrInputNotFound, err, "Cannot read summary file %s: %v", path, err)
+	}
+	if _, body, err := splitFrontMatter(content); err == nil {
+		content = body
+	}
+	dir := filepath.Dir(path)
+
+	var entries []summaryEntry
+	var indents []int // Indentation of the enclosing list items, outermost first
+	inFence := false
+	for _, line := range strings.Split(string(bytes.ReplaceAll(content, []byte("\t"), []byte("    "))), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if isFenceLine(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		level, text := 1, strings.TrimSpace(line)
+		if m := summaryItem.FindStringSubmatch(line); m != nil {
+			indent := len(m[1])
+			for len(indents) > 0 && indents[len(indents)-1] > indent {
+				indents = indents[:len(indents)-1]
+			}
+			if len(indents) == 0 || indents[len(indents)-1] < indent {
+				indents = append(indents, indent)
+			}
+			level, text = len(indents), m[2]
+		} else {
+			indents = nil
+		}
+
+		m := summaryLink.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		entry := summaryEntry{Title: strings.TrimSpace(m[1]), Level: level}
+		if target := summaryTarget(m[2]); target != "" {
+			entry.Path = filepath.Join(dir, filepath.FromSlash(target))
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// summaryTarget returns the file path of a summary link, without anchor, or an empty string for links to
+// other sites and for draft chapters
+func summaryTarget(link string) string {
+	link = strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">")
+	if i := strings.IndexByte(link, '#'); i >= 0 {
+		link = link[:i]
+	}
+	if unescaped, err := url.PathUnescape(link); err == nil {
+		link = unescaped
+	}
+	if link == "" || strings.Contains(link, "://") || strings.HasPrefix(link, "mailto:") {
+		return ""
+	}
+	return link
+}
@@ -0,0 +1,399 @@
+package merger
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PageText is the text extracted from one page of a PDF file
+type PageText struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+}
+
+// ExtractText returns the text of the pages of the PDF file at path selected by the page range expression,
+// or of all pages when it is empty. Text is read from the content streams and decoded through the fonts'
+// ToUnicode maps or encodings, so pages that are scanned images, or use fonts without either, have no text.
+func ExtractText(path, ranges string) ([]PageText, error) {
+	return ExtractTextContext(context.Background(), path, ranges)
+}
+
+// ExtractTextContext is ExtractText stopping early when ctx is cancelled
+func ExtractTextContext(ctx context.Context, path, ranges string) ([]PageText, error) {
+	pdf, err := readPDFFile(ctx, path, model.VALIDATE)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectedPages(ranges, pdf.PageCount)
+	if err != nil {
+		return nil, err
+	}
+	return extractPagesText(ctx, pdf, pages)
+}
+
+// selectedPages parses a page range expression, an empty expression selects every page
+func selectedPages(ranges string, pageCount int) ([]int, error) {
+	if ranges == "" {
+		pages := make([]int, pageCount)
+		for i := range pages {
+			pages[i] = i + 1
+		}
+		return pages, nil
+	}
+	return ParsePageRanges(ranges, pageCount)
+}
+
+// extractPagesText returns the text of the given pages of pdf
+func extractPagesText(ctx context.Context, pdf *model.Context, pages []int) ([]PageText, error) {
+	e := newTextExtractor(pdf)
+	texts := make([]PageText, 0, len(pages))
+	for _, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lines, err := e.pageLines(page)
+		if err != nil {
+			return nil, newError(ErrInvalidFile, err, "Cannot read the text of page %d: %s", page, cleanPDFError(err))
+		}
+		text := make([]string, len(lines))
+		for i, line := range lines {
+			text[i] = line.text
+		}
+		texts = append(texts, PageText{Page: page, Text: strings.Join(text, "\n")})
+	}
+	return texts, nil
+}
+
+// textRun is a piece of text shown by one operator, positioned in page space
+type textRun struct {
+	text       string
+	x, y, endX float64
+	size       float64 // Font size in page space
+}
+
+// textLine is a line of text assembled from the runs on one baseline
+type textLine struct {
+	text       string
+	x, y, endX float64
+	size       float64 // Largest font size on the line
+}
+
+// textState is the part of the graphics state used to position text, saved by q and restored by Q
+type textState struct {
+	ctm         matrix
+	font        *textFont
+	size        float64
+	leading     float64
+	charSpacing float64
+	wordSpacing float64
+	scale       float64 // Horizontal scaling, 1 for 100%
+}
+
+// maxFormDepth limits the nesting of form XObjects, which can refer to themselves in damaged files
+const maxFormDepth = 8
+
+// textExtractor runs the text operators of content streams and collects the text they show
+type textExtractor struct {
+	pdf     *model.Context
+	fonts   map[int]*textFont // Fonts loaded from indirect objects, by object number
+	runs    []textRun
+	state   textState
+	saved   []textState
+	tm, tlm matrix // Text matrix and text line matrix
+}
+
+func newTextExtractor(pdf *model.Context) *textExtractor {
+	return &textExtractor{pdf: pdf, fonts: map[int]*textFont{}}
+}
+
+// pageLines returns the lines of text of a page, in content stream order
+func (e *textExtractor) pageLines(page int) ([]textLine, error) {
+	d, _, inherited, err := e.pdf.PageDict(page, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := e.pdf.PageContent(d)
+	if err == model.ErrNoContent {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	e.runs = nil
+	e.saved = nil
+	e.state = textState{ctm: identityMatrix, scale: 1}
+	e.run(content, inherited.Resources, 0)
+	return textLines(e.runs), nil
+}
+
+// run interprets a content stream using resources
+func (e *textExtractor) run(content []byte, resources types.Dict, depth int) {
+	parseContent(content, func(op string, args []any) {
+		s := &e.state
+		switch op {
+		case "q":
+			e.saved = append(e.saved, e.state)
+		case "Q":
+			if n := len(e.saved); n > 0 {
+				e.state = e.saved[n-1]
+				e.saved = e.saved[:n-1]
+			}
+		case "cm":
+			if m, ok := matrixOperand(args); ok {
+				s.ctm = m.multiply(s.ctm)
+			}
+		case "BT":
+			e.tm, e.tlm = identityMatrix, identityMatrix
+		case "Tf":
+			if len(args) == 2 {
+				name, _ := args[0].(contentName)
+				s.font = e.font(resources, string(name))
+				s.size = numberOperand(args, 1)
+			}
+		case "Tc":
+			s.charSpacing = numberOperand(args, 0)
+		case "Tw":
+			s.wordSpacing = numberOperand(args, 0)
+		case "Tz":
+			s.scale = numberOperand(args, 0) / 100
+		case "TL":
+			s.leading = numberOperand(args, 0)
+		case "Td":
+			e.moveLine(numberOperand(args, 0), numberOperand(args, 1))
+		case "TD":
+			s.leading = -numberOperand(args, 1)
+			e.moveLine(numberOperand(args, 0), numberOperand(args, 1))
+		case "Tm":
+			if m, ok := matrixOperand(args); ok {
+				e.tm, e.tlm = m, m
+			}
+		case "T*":
+			e.moveLine(0, -s.leading)
+		case "Tj":
+			e.show(stringOperand(args, 0))
+		case "'":
+			e.moveLine(0, -s.leading)
+			e.show(stringOperand(args, 0))
+		case "\"":
+			s.wordSpacing = numberOperand(args, 0)
+			s.charSpacing = numberOperand(args, 1)
+			e.moveLine(0, -s.leading)
+			e.show(stringOperand(args, 2))
+		case "TJ":
+			if len(args) == 1 {
+				elems, _ := args[0].([]any)
+				for _, elem := range elems {
+					switch elem := elem.(type) {
+					case []byte:
+						e.show(elem)
+					case float64:
+						e.tm = translation(-elem/1000*s.size*s.scale, 0).multiply(e.tm)
+					}
+				}
+			}
+		case "Do":
+			if len(args) == 1 && depth < maxFormDepth {
+				name, _ := args[0].(contentName)
+				e.form(resources, string(name), depth)
+			}
+		}
+	})
+}
+
+// moveLine starts a new line of text offset by (x, y) from the start of the current one
+func (e *textExtractor) moveLine(x, y float64) {
+	e.tlm = translation(x, y).multiply(e.tlm)
+	e.tm = e.tlm
+}
+
+// show adds the text of a string shown with the current font, and advances the text matrix past it.
+// Character and word spacing wide enough to separate words is turned into spaces, spaces squeezed
+// to nothing by negative word spacing are dropped.
+func (e *textExtractor) show(b []byte) {
+	s := &e.state
+	if s.font == nil || len(b) == 0 {
+		return
+	}
+
+	// Gaps from 15% of the font size on are taken as word separators
+	gap := math.Abs(s.size) * 0.15
+	start := e.tm.multiply(s.ctm)
+	end := start
+	glyphs := s.font.decode(b)
+	var text strings.Builder
+	for i, g := range glyphs {
+		width := g.width / 1000 * s.size
+		spacing := s.charSpacing
+		if g.space {
+			spacing += s.wordSpacing
+		}
+
+		isSpace := g.text != "" && strings.TrimSpace(g.text) == ""
+		if !isSpace {
+			text.WriteString(ligatures.Replace(g.text))
+		} else if width+spacing >= gap {
+			text.WriteByte(' ')
+		}
+		if !isSpace && spacing >= gap && i < len(glyphs)-1 {
+			text.WriteByte(' ')
+		}
+
+		// The end of the run is the end of its last glyph, without the spacing that follows it
+		e.tm = translation(width*s.scale, 0).multiply(e.tm)
+		end = e.tm.multiply(s.ctm)
+		e.tm = translation(spacing*s.scale, 0).multiply(e.tm)
+	}
+
+	e.runs = append(e.runs, textRun{
+		text: text.String(),
+		x:    start[4],
+		y:    start[5],
+		endX: end[4],
+		size: s.size * math.Hypot(start[2], start[3]),
+	})
+}
+
+// form runs the content stream of the form XObject name
+func (e *textExtractor) form(resources types.Dict, name string, depth int) {
+	xobjects, err := e.pdf.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return
+	}
+	sd, _, err := e.pdf.DereferenceStreamDict(xobjects[name])
+	if err != nil || sd == nil || sd.Dict.NameEntry("Subtype") == nil || *sd.Dict.NameEntry("Subtype") != "Form" {
+		return
+	}
+	if err := sd.Decode(); err != nil {
+		return
+	}
+
+	formResources := resources
+	if d, err := e.pdf.DereferenceDict(sd.Dict["Resources"]); err == nil && d != nil {
+		formResources = d
+	}
+
+	// The form runs with its own graphics and text state, which is restored afterwards
+	saved, tm, tlm, depthSaved := e.state, e.tm, e.tlm, len(e.saved)
+	if m, ok := e.matrixEntry(sd.Dict, "Matrix"); ok {
+		e.state.ctm = m.multiply(e.state.ctm)
+	}
+	e.run(sd.Content, formResources, depth+1)
+	e.state, e.tm, e.tlm, e.saved = saved, tm, tlm, e.saved[:depthSaved]
+}
+
+// font returns the font resource name, or nil if it cannot be loaded
+func (e *textExtractor) font(resources types.Dict, name string) *textFont {
+	fonts, err := e.pdf.DereferenceDict(resources["Font"])
+	if err != nil || fonts == nil {
+		return nil
+	}
+	obj, ok := fonts[name]
+	if !ok {
+		return nil
+	}
+	if ref, ok := obj.(types.IndirectRef); ok {
+		if f, ok := e.fonts[ref.ObjectNumber.Value()]; ok {
+			return f
+		}
+		f := e.loadFont(obj)
+		e.fonts[ref.ObjectNumber.Value()] = f
+		return f
+	}
+	return e.loadFont(obj)
+}
+
+// matrixEntry returns the matrix stored under key in d
+func (e *textExtractor) matrixEntry(d types.Dict, key string) (matrix, bool) {
+	a, err := e.pdf.DereferenceArray(d[key])
+	if err != nil || len(a) != 6 {
+		return matrix{}, false
+	}
+	var m matrix
+	for i, o := range a {
+		n, err := e.pdf.DereferenceNumber(o)
+		if err != nil {
+			return matrix{}, false
+		}
+		m[i] = n
+	}
+	return m, true
+}
+
+// numberOperand returns the number operand at index i, 0 if it is missing
+func numberOperand(args []any, i int) float64 {
+	if i < len(args) {
+		if n, ok := args[i].(float64); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// stringOperand returns the string operand at index i, nil if it is missing
+func stringOperand(args []any, i int) []byte {
+	if i < len(args) {
+		if b, ok := args[i].([]byte); ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// matrixOperand returns the six operands of cm or Tm as a matrix
+func matrixOperand(args []any) (matrix, bool) {
+	if len(args) != 6 {
+		return matrix{}, false
+	}
+	var m matrix
+	for i := range m {
+		n, ok := args[i].(float64)
+		if !ok {
+			return matrix{}, false
+		}
+		m[i] = n
+	}
+	return m, true
+}
+
+// textLines assembles runs into lines: a run continues the previous line if it is on the same baseline and
+// does not start before its end. A space is added between runs separated by a gap.
+func textLines(runs []textRun) []textLine {
+	var lines []textLine
+	for _, r := range runs {
+		if r.text == "" {
+			continue
+		}
+		if n := len(lines); n > 0 {
+			l := &lines[n-1]
+			size := math.Max(math.Max(l.size, r.size), 1)
+			if math.Abs(r.y-l.y) < size/2 && r.x > l.endX-size {
+				// A run moved back over the trailing space of the line, as in tightly kerned titles, joins the word
+				if r.x < l.endX-size*0.15 {
+					l.text = strings.TrimSuffix(l.text, " ")
+				}
+				if r.x-l.endX > size*0.15 && !strings.HasSuffix(l.text, " ") && !strings.HasPrefix(r.text, " ") {
+					l.text += " "
+				}
+				l.text += r.text
+				l.endX = math.Max(l.endX, r.endX)
+				l.size = math.Max(l.size, r.size)
+				continue
+			}
+		}
+		lines = append(lines, textLine{text: r.text, x: r.x, y: r.y, endX: r.endX, size: r.size})
+	}
+
+	// Drop lines holding only white space, e.g. spaces shown to separate columns
+	kept := lines[:0]
+	for _, l := range lines {
+		if l.text = strings.TrimSpace(l.text); l.text != "" {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,243 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// commentStyle is the line comment syntax of a programming language, with an end for block comments
+type commentStyle struct {
+	start, end string
+}
+
+// codeComments maps the extensions of source files to their comment syntax
+var codeComments = map[string]commentStyle{
+	".sql": {"--", ""}, ".lua": {"--", ""}, ".hs": {"--", ""},
+	".go": {"//", ""}, ".c": {"//", ""}, ".h": {"//", ""}, ".cpp": {"//", ""}, ".hpp": {"//", ""}, ".cc": {"//", ""},
+	".java": {"//", ""}, ".kt": {"//", ""}, ".scala": {"//", ""}, ".cs": {"//", ""}, ".swift": {"//", ""},
+	".rs": {"//", ""}, ".js": {"//", ""}, ".mjs": {"//", ""}, ".jsx": {"//", ""}, ".ts": {"//", ""}, ".tsx": {"//", ""},
+	".php": {"//", ""}, ".proto": {"//", ""},
+	".py": {"#", ""}, ".rb": {"#", ""}, ".pl": {"#", ""}, ".r": {"#", ""}, ".sh": {"#", ""}, ".bash": {"#", ""},
+	".zsh": {"#", ""}, ".ps1": {"#", ""}, ".yaml": {"#", ""}, ".yml": {"#", ""}, ".toml": {"#", ""}, ".tf": {"#", ""},
+	".ini": {";", ""}, ".clj": {";;", ""}, ".lisp": {";;", ""}, ".el": {";;", ""},
+	".css": {"/*", " */"}, ".xml": {"<!--", " -->"}, ".vue": {"<!--", " -->"},
+}
+
+// codeExtensions returns the extensions of the source files with a known comment syntax, sorted
+func codeExtensions() []string {
+	exts := make([]string, 0, len(codeComments))
+	for ext := range codeComments {
+		exts = append(exts, ext)
+	}
+	slices.Sort(exts)
+	return exts
+}
+
+// codeBanner returns the comment line naming a source file, in the comment syntax of its extension
+func codeBanner(in Input) string {
+	style, ok := codeComments[strings.ToLower(filepath.Ext(in.displayName()))]
+	if !ok {
+		style = commentStyle{"#", ""}
+	}
+	return fmt.Sprintf("%s File: %s%s", style.start, in.displayName(), style.end)
+}
+
+// textBanner returns the line naming a text file, as written by tail for several files
+func textBanner(in Input) string {
+	return fmt.Sprintf("==> %s <==", in.displayName())
+}
+
+// readText returns the content of a text input converted to UTF-8, with its line endings normalized as set in opts.Text
+func readText(in Input, opts Options) ([]byte, error) {
+	content, err := in.readAll()
+	if err != nil {
+		return nil, err
+	}
+	content, enc, err := decodeText(content, opts.Text.Encoding)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOptions) {
+			return nil, err
+		}
+		return nil, &InvalidFileError{Path: in.displayName(), Reason: fmt.Sprintf("cannot decode as %s: %v", enc, err)}
+	}
+	if enc != "utf-8" {
+		opts.Logger.Info("Input converted to UTF-8", "path", in.displayName(), "encoding", enc)
+	}
+	if opts.Text.LineEndings != "" {
+		content = normalizeLineEndings(content)
+	}
+	return content, nil
+}
+
+// mergeText concatenates plain text inputs, preceded by a banner line when opts.Text.Banners is set
+func mergeText(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	return concatText(ctx, inputs, w, opts, textBanner)
+}
+
+// mergeCode concatenates source files, preceded by a comment naming them when opts.Text.Banners is set
+func mergeCode(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	return concatText(ctx, inputs, w, opts, codeBanner)
+}
+
+// concatText writes the text inputs to w, each ending with a newline. With opts.Text.Banners, each input is
+// preceded by the line banner returns for it, and separated from the previous one by an empty line.
+func concatText(ctx context.Context, inputs []Input, w io.Writer, opts Options, banner func(Input) string) (*MergeResult, error) {
+	if opts.Text.LineEndings == LineEndingCRLF {
+		w = crlfWriter{w: w}
+	}
+	out := &progressWriter{w: w, progress: opts.Progress}
+
+	for i, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, err
+		}
+
+		content, err := readText(in, opts)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFile) {
+				err = fmt.Errorf("Failed to read file %s: %w", in.displayName(), err)
+			}
+			return &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, err
+		}
+
+		var buf bytes.Buffer
+		if opts.Text.Banners {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(banner(in) + "\n")
+		}
+		buf.Write(content)
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			buf.WriteString("\n")
+		}
+		if _, err := out.Write(buf.Bytes()); err != nil {
+			if !errors.Is(err, ErrOutputFailed) {
+				err = newError(ErrOutputFailed, err, "Cannot write output: %v", err)
+			}
+			return &MergeResult{
+				Success:      false,
+				ErrorMessage: err.Error(),
+			}, err
+		}
+		opts.Progress.report(ProgressEvent{Stage: ProgressMerged, File: in.displayName(), Current: i + 1, Total: len(inputs)})
+	}
+
+	return &MergeResult{
+		Success:     true,
+		MergedFiles: len(inputs),
+		FilesList:   inputNames(inputs),
+	}, nil
+}
+
+// mergeCSV concatenates CSV inputs, writing the header row of the first file once. Every other file must start
+// with the same header, which is left out. The field delimiter of each file is detected from its header.
+func mergeCSV(ctx context.Context, inputs []Input, w io.Writer, opts Options) (*MergeResult, error) {
+	out := &progressWriter{w: w, progress: opts.Progress}
+	cw := csv.NewWriter(out)
+	cw.UseCRLF = opts.Text.LineEndings == LineEndingCRLF
+
+	var header []string
+	var headerFile string
+	fail := func(err error) (*MergeResult, error) {
+		return &MergeResult{
+			Success:      false,
+			ErrorMessage: err.Error(),
+		}, err
+	}
+
+	for i, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return fail(err)
+		}
+
+		content, err := readText(in, opts)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFile) {
+				err = fmt.Errorf("Failed to read file %s: %w", in.displayName(), err)
+			}
+			return fail(err)
+		}
+		r := csv.NewReader(bytes.NewReader(content))
+		r.Comma = csvDelimiter(content)
+		r.FieldsPerRecord = -1 // Rows may have fewer or more fields than the header, as exports often do
+		records, err := r.ReadAll()
+		if err != nil {
+			return fail(&InvalidFileError{Path: in.displayName(), Reason: err.Error()})
+		}
+
+		switch {
+		case len(records) == 0:
+			opts.Logger.Warn("Empty CSV file", "path", in.displayName())
+		case header == nil:
+			header, headerFile = records[0], in.displayName()
+			cw.Comma = r.Comma
+			cw.WriteAll(records)
+		case !slices.Equal(trimFields(records[0]), trimFields(header)):
+			return fail(&InvalidFileError{Path: in.displayName(), Reason: fmt.Sprintf("header %q does not match the header %q of %s",
+				strings.Join(records[0], string(r.Comma)), strings.Join(header, string(cw.Comma)), headerFile)})
+		default:
+			cw.WriteAll(records[1:])
+		}
+		if err := cw.Error(); err != nil {
+			if !errors.Is(err, ErrOutputFailed) {
+				err = newError(ErrOutputFailed, err, "Cannot write output: %v", err)
+			}
+			return fail(err)
+		}
+		opts.Progress.report(ProgressEvent{Stage: ProgressMerged, File: in.displayName(), Current: i + 1, Total: len(inputs)})
+	}
+
+	return &MergeResult{
+		Success:     true,
+		MergedFiles: len(inputs),
+		FilesList:   inputNames(inputs),
+	}, nil
+}
+
+// csvDelimiter returns the most frequent of comma, semicolon and tab in the first line of content, outside
+// of quoted fields, comma when there is none
+func csvDelimiter(content []byte) rune {
+	counts := map[rune]int{}
+	quoted := false
+	for _, c := range string(content) {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == '\n' && !quoted {
+			break
+		}
+		if !quoted && (c == ',' || c == ';' || c == '\t') {
+			counts[c]++
+		}
+	}
+	delimiter := ','
+	for _, c := range []rune{';', '\t'} {
+		if counts[c] > counts[delimiter] {
+			delimiter = c
+		}
+	}
+	return delimiter
+}
+
+// trimFields returns fields without surrounding white space, to compare header rows
+func trimFields(fields []string) []string {
+	trimmed := make([]string, len(fields))
+	for i, f := range fields {
+		trimmed[i] = strings.TrimSpace(f)
+	}
+	return trimmed
+}
@@ -0,0 +1,60 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMergeCSV(t *testing.T) {
+	tests := []struct {
+		desc    string
+		files   []string
+		want    string
+		invalid bool
+	}{
+		{
+			desc:  "header written once",
+			files: []string{"id,name\n1,a\n", "id,name\n2,b\n"},
+			want:  "id,name\n1,a\n2,b\n",
+		},
+		{
+			desc:  "ragged rows",
+			files: []string{"id,name,note\n1,a\n2,b,x,extra\n", "id,name,note\n3\n"},
+			want:  "id,name,note\n1,a\n2,b,x,extra\n3\n",
+		},
+		{
+			desc:  "semicolon delimiter",
+			files: []string{"id;name\n1;a\n", "id;name\n2;b\n"},
+			want:  "id;name\n1;a\n2;b\n",
+		},
+		{
+			desc:    "different headers",
+			files:   []string{"id,name\n1,a\n", "id,title\n2,b\n"},
+			invalid: true,
+		},
+	}
+	for _, tt := range tests {
+		var inputs []Input
+		for i, content := range tt.files {
+			inputs = append(inputs, Input{Name: string(rune('a'+i)) + ".csv", Reader: strings.NewReader(content)})
+		}
+		var out bytes.Buffer
+		_, err := MergeContext(context.Background(), Options{Inputs: inputs, Output: &out, Format: FormatCSV})
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("%s: Merge() error = %v, want an invalid file", tt.desc, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Merge() error = %v", tt.desc, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: Merge() = %q, want %q", tt.desc, out.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ValidationMode selects how strictly PDF files are checked before merging
+type ValidationMode string
+
+const (
+	// ValidationRelaxed accepts files with common, recoverable deviations from the PDF specification
+	ValidationRelaxed ValidationMode = "relaxed"
+	// ValidationStrict rejects any file that does not conform to the PDF specification
+	ValidationStrict ValidationMode = "strict"
+)
+
+// ValidationOptions controls the validation pass run on every input before merging PDF files
+type ValidationOptions struct {
+	Mode        ValidationMode `json:"mode,omitempty"`
+	Repair      bool           `json:"repair,omitempty"`      // Attempt to repair invalid files by rebuilding their cross-reference table
+	SkipInvalid bool           `json:"skipInvalid,omitempty"` // Leave invalid files out of the merge instead of failing
+}
+
+// DefaultValidationOptions returns the validation options used by MergePDFs and MergePDFFiles
+func DefaultValidationOptions() ValidationOptions {
+	return ValidationOptions{Mode: ValidationRelaxed}
+}
+
+// ParseValidationMode converts a string to a ValidationMode, an empty string selects relaxed mode
+func ParseValidationMode(s string) (ValidationMode, error) {
+	switch ValidationMode(strings.ToLower(s)) {
+	case "", ValidationRelaxed:
+		return ValidationRelaxed, nil
+	case ValidationStrict:
+		return ValidationStrict, nil
+	}
+	return "", fmt.Errorf("Unknown validation mode %q, must be strict or relaxed", s)
+}
+
+// SkippedFile stores a file that was left out of a merge and the reason
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ValidatePDFFile checks that a PDF file can be read and conforms to the given validation mode.
+// Validation failures are returned as *InvalidFileError.
+func ValidatePDFFile(path string, mode ValidationMode) error {
+	return validateInput(context.Background(), Input{Path: path}, mode)
+}
+
+// validateInput checks a PDF input like ValidatePDFFile, stopping early when ctx is cancelled
+func validateInput(ctx context.Context, in Input, mode ValidationMode) error {
+	conf := validationConfig(mode)
+	pdfCtx, err := readPDFInput(ctx, in, conf)
+	if err == nil && conf.Optimize {
+		err = api.OptimizeContext(pdfCtx)
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return &InvalidFileError{Path: in.displayName(), Reason: cleanPDFError(err)}
+	}
+	return nil
+}
+
+// readPDFInput reads and validates a PDF input, stopping early when ctx is cancelled
+func readPDFInput(ctx context.Context, in Input, conf *model.Configuration) (*model.Context, error) {
+	rs, release, err := in.open()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return readPDF(ctx, rs, conf)
+}
+
+// readPDF reads and validates a PDF from rs, stopping early when ctx is cancelled
+func readPDF(ctx context.Context, rs io.ReadSeeker, conf *model.Configuration) (*model.Context, error) {
+	pdfCtx, err := pdfcpu.ReadWithContext(ctx, rs, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.ValidateContext(pdfCtx); err != nil {
+		return nil, err
+	}
+	return pdfCtx, nil
+}
+
+// RepairPDFFile attempts to repair a damaged PDF file and writes the repaired document to outputFile.
+// The file is first re-read in relaxed mode and rewritten with a fresh cross-reference table;
+// if it cannot be read at all, the cross-reference table is reconstructed by scanning the file for objects.
+func RepairPDFFile(path, outputFile string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	pdfCtx, err := repairPDF(content)
+	if err != nil {
+		return err
+	}
+	return api.WriteContextFile(pdfCtx, outputFile)
+}
+
+// repairPDF reads a damaged PDF in relaxed mode, reconstructing its cross-reference table if needed
+func repairPDF(content []byte) (*model.Context, error) {
+	pdfCtx, err := api.ReadAndValidate(bytes.NewReader(content), validationConfig(ValidationRelaxed))
+	if err == nil {
+		return pdfCtx, nil
+	}
+
+	rebuilt, rebuildErr := rebuildXRefTable(content)
+	if rebuildErr != nil {
+		return nil, fmt.Errorf("cross-reference reconstruction failed: %v", rebuildErr)
+	}
+	pdfCtx, err = api.ReadAndValidate(bytes.NewReader(rebuilt), validationConfig(ValidationRelaxed))
+	if err != nil {
+		return nil, fmt.Errorf("File is still invalid after cross-reference reconstruction: %s", cleanPDFError(err))
+	}
+	return pdfCtx, nil
+}
+
+// repairInput repairs a damaged PDF input in memory and returns an input with the repaired content under the same name
+func repairInput(in Input) (Input, error) {
+	content, err := in.readAll()
+	if err != nil {
+		return Input{}, err
+	}
+
+	pdfCtx, err := repairPDF(content)
+	if err != nil {
+		return Input{}, err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(pdfCtx, &buf); err != nil {
+		return Input{}, err
+	}
+	return Input{Name: in.displayName(), Reader: bytes.NewReader(buf.Bytes())}, nil
+}
+
+// validationConfig creates a pdfcpu configuration for the given validation mode
+func validationConfig(mode ValidationMode) *model.Configuration {
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.VALIDATE
+	conf.ValidationMode = model.ValidationRelaxed
+	if mode == ValidationStrict {
+		conf.ValidationMode = model.ValidationStrict
+	}
+	return conf
+}
+
+// cleanPDFError turns a pdfcpu error into a readable reason, leaving out the dumps pdfcpu adds after the first line
+func cleanPDFError(err error) string {
+	reason, _, _ := strings.Cut(err.Error(), "\n")
+	return strings.TrimSpace(strings.ReplaceAll(reason, "pdfcpu: ", ""))
+}
+
+var (
+	objHeaderPattern = regexp.MustCompile(`(?m)(?:^|[\r\n\s])(\d+)\s+(\d+)\s+obj\b`)
+	rootRefPattern   = regexp.MustCompile(`/Root\s+(\d+)\s+(\d+)\s+R`)
+	infoRefPattern   = regexp.MustCompile(`/Info\s+(\d+)\s+(\d+)\s+R`)
+	catalogPattern   = regexp.MustCompile(`/Type\s*/Catalog\b`)
+	objStmPattern    = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+)
+
+// rebuildXRefTable reconstructs the cross-reference table of a damaged PDF by locating all
+// "N G obj" headers, and appends the new table and trailer to a copy of content
+func rebuildXRefTable(content []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(content, "\x00\t\r\n "), []byte("%PDF-")) {
+		return nil, errors.New("missing PDF header")
+	}
+	if objStmPattern.Match(content) {
+		return nil, errors.New("objects stored in object streams cannot be recovered")
+	}
+
+	type objEntry struct {
+		offset     int
+		generation int
+	}
+
+	// Later definitions of an object replace earlier ones, as with incremental updates
+	objects := make(map[int]objEntry)
+	maxObj := 0
+	for _, m := range objHeaderPattern.FindAllSubmatchIndex(content, -1) {
+		objNr, err := strconv.Atoi(string(content[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		gen, err := strconv.Atoi(string(content[m[4]:m[5]]))
+		if err != nil {
+			continue
+		}
+		objects[objNr] = objEntry{offset: m[2], generation: gen}
+		if objNr > maxObj {
+			maxObj = objNr
+		}
+	}
+	if len(objects) == 0 {
+		return nil, errors.New("no objects found")
+	}
+
+	// Locate the document catalog, preferring the last trailer reference
+	var root string
+	if matches := rootRefPattern.FindAllSubmatch(content, -1); len(matches) > 0 {
+		last := matches[len(matches)-1]
+		root = fmt.Sprintf("%s %s R", last[1], last[2])
+	} else {
+		objNrs := make([]int, 0, len(objects))
+		for objNr := range objects {
+			objNrs = append(objNrs, objNr)
+		}
+		sort.Ints(objNrs)
+		for _, objNr := range objNrs {
+			start := objects[objNr].offset
+			end := bytes.Index(content[start:], []byte("endobj"))
+			if end < 0 {
+				continue
+			}
+			if catalogPattern.Match(content[start : start+end]) {
+				root = fmt.Sprintf("%d %d R", objNr, objects[objNr].generation)
+				break
+			}
+		}
+	}
+	if root == "" {
+		return nil, errors.New("document catalog not found")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(content)
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n", maxObj+1)
+	buf.WriteString("0000000000 65535 f\r\n")
+	for objNr := 1; objNr <= maxObj; objNr++ {
+		if entry, ok := objects[objNr]; ok {
+			fmt.Fprintf(&buf, "%010d %05d n\r\n", entry.offset, entry.generation)
+		} else {
+			buf.WriteString("0000000000 00000 f\r\n")
+		}
+	}
+
+	fmt.Fprintf(&buf, "trailer\n<</Size %d /Root %s", maxObj+1, root)
+	if matches := infoRefPattern.FindAllSubmatch(content, -1); len(matches) > 0 {
+		last := matches[len(matches)-1]
+		fmt.Fprintf(&buf, " /Info %s %s R", last[1], last[2])
+	}
+	fmt.Fprintf(&buf, ">>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	return buf.Bytes(), nil
+}
+
+// validatedPDFs is the outcome of the validation pass over a list of PDF inputs
+type validatedPDFs struct {
+	inputs        []Input // Inputs to merge, with repaired content where needed
+	skippedFiles  []SkippedFile
+	repairedFiles []string
+}
+
+// validatePDFs checks every input before merging. Invalid inputs are repaired or skipped according to opts,
+// otherwise all validation failures are returned together as *InvalidFileError values.
+func validatePDFs(ctx context.Context, inputs []Input, opts ValidationOptions, logger *slog.Logger, progress ProgressFunc) (*validatedPDFs, error) {
+	result := &validatedPDFs{}
+	var invalid []error
+
+	for i, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		name := in.displayName()
+		err := validateInput(ctx, in, opts.Mode)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		progress.report(ProgressEvent{Stage: ProgressValidated, File: name, Current: i + 1, Total: len(inputs)})
+		if err == nil {
+			result.inputs = append(result.inputs, in)
+			continue
+		}
+
+		reason := err.Error()
+		var invalidErr *InvalidFileError
+		if errors.As(err, &invalidErr) {
+			reason = invalidErr.Reason
+		}
+
+		logger.Warn("File failed validation", "path", name, "reason", reason)
+
+		if opts.Repair {
+			repaired, repairErr := repairInput(in)
+			if repairErr == nil {
+				repairErr = validateInput(ctx, repaired, opts.Mode)
+			}
+			if repairErr == nil {
+				logger.Info("File repaired", "path", name)
+				result.inputs = append(result.inputs, repaired)
+				result.repairedFiles = append(result.repairedFiles, name)
+				continue
+			}
+			reason = fmt.Sprintf("%s (repair failed: %v)", reason, repairErr)
+		}
+
+		if opts.SkipInvalid {
+			logger.Warn("Invalid file skipped", "path", name, "reason", reason)
+			result.skippedFiles = append(result.skippedFiles, SkippedFile{Path: name, Reason: reason})
+			continue
+		}
+
+		invalid = append(invalid, &InvalidFileError{Path: name, Reason: reason})
+	}
+
+	if len(invalid) > 0 {
+		return nil, errors.Join(invalid...)
+	}
+
+	return result, nil
+}
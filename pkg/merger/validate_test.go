@@ -0,0 +1,188 @@
+package merger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func TestRebuildXRefTable(t *testing.T) {
+	tests := []struct {
+		file string
+		desc string
+	}{
+		{"valid.pdf", "intact cross-reference table"},
+		{"broken-xref-offset.pdf", "table entries pointing into other objects"},
+		{"missing-xref.pdf", "no cross-reference table or trailer"},
+	}
+	for _, tt := range tests {
+		content, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rebuilt, err := rebuildXRefTable(content)
+		if err != nil {
+			t.Errorf("rebuildXRefTable(%s, %s) error = %v", tt.file, tt.desc, err)
+			continue
+		}
+		if !bytes.HasPrefix(rebuilt, content) {
+			t.Errorf("rebuildXRefTable(%s) did not keep the original content", tt.file)
+		}
+
+		// Every in-use entry of the new table points at the header of its object
+		table := rebuilt[bytes.LastIndex(rebuilt, []byte("\nxref\n")):]
+		entries := regexp.MustCompile(`(\d{10}) (\d{5}) n`).FindAllSubmatch(table, -1)
+		if len(entries) != 4 {
+			t.Errorf("rebuildXRefTable(%s) wrote %d objects, want 4", tt.file, len(entries))
+		}
+		for i, entry := range entries {
+			offset, _ := strconv.Atoi(string(entry[1]))
+			header := []byte(strconv.Itoa(i+1) + " 0 obj")
+			if !bytes.HasPrefix(rebuilt[offset:], header) {
+				t.Errorf("rebuildXRefTable(%s) entry %d points at %q, want %q", tt.file, i+1, rebuilt[offset:offset+len(header)], header)
+			}
+		}
+
+		pdfCtx, err := repairPDF(content)
+		if err != nil {
+			t.Errorf("repairPDF(%s) error = %v", tt.file, err)
+			continue
+		}
+		if pdfCtx.PageCount != 1 {
+			t.Errorf("repairPDF(%s) has %d pages, want 1", tt.file, pdfCtx.PageCount)
+		}
+	}
+}
+
+func TestRebuildXRefTableErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		content string
+	}{
+		{"no PDF header", "1 0 obj\n<< /Type /Catalog >>\nendobj\n"},
+		{"no objects", "%PDF-1.4\n%%EOF\n"},
+		{"no catalog", "%PDF-1.4\n1 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n%%EOF\n"},
+		{"object streams", "%PDF-1.5\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 >>\nendobj\n%%EOF\n"},
+	}
+	for _, tt := range tests {
+		if _, err := rebuildXRefTable([]byte(tt.content)); err == nil {
+			t.Errorf("rebuildXRefTable() with %s succeeded, want an error", tt.desc)
+		}
+	}
+}
+
+func TestCleanPDFError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want string
+	}{
+		{"pdfcpu: please provide the correct password", "please provide the correct password"},
+		{"validateResources: pdfcpu: missing required resource subdict: Font\nPageResourceNames:\nColorSpace: \nFont: F1\n",
+			"validateResources: missing required resource subdict: Font"},
+		{"no pdfcpu prefix", "no pdfcpu prefix"},
+	}
+	for _, tt := range tests {
+		if got := cleanPDFError(errors.New(tt.err)); got != tt.want {
+			t.Errorf("cleanPDFError(%q) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// mergeTestPDFs merges files of testdata into a temporary file with the given validation options
+func mergeTestPDFs(t *testing.T, validation ValidationOptions, files ...string) (*MergeResult, error) {
+	t.Helper()
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = filepath.Join("testdata", file)
+	}
+	return MergeContext(context.Background(), Options{
+		Files:      paths,
+		OutputFile: filepath.Join(t.TempDir(), "merged.pdf"),
+		Format:     FormatPDF,
+		PDF:        PDFOptions{Validation: validation},
+	})
+}
+
+func TestMergeValidationModes(t *testing.T) {
+	tests := []struct {
+		file    string
+		mode    ValidationMode
+		wantErr bool
+	}{
+		{"valid.pdf", ValidationStrict, false},
+		{"valid.pdf", ValidationRelaxed, false},
+		{"invalid-date.pdf", ValidationStrict, true}, // The creation date is not a PDF date
+		{"invalid-date.pdf", ValidationRelaxed, false},
+		{"invalid.pdf", ValidationStrict, true}, // The page mode is not a valid name
+		{"invalid.pdf", ValidationRelaxed, true},
+	}
+	for _, tt := range tests {
+		_, err := mergeTestPDFs(t, ValidationOptions{Mode: tt.mode}, "small.pdf", tt.file)
+		if tt.wantErr {
+			var invalid *InvalidFileError
+			if !errors.As(err, &invalid) || invalid.Path != filepath.Join("testdata", tt.file) {
+				t.Errorf("merge %s in %s mode error = %v, want an invalid file error", tt.file, tt.mode, err)
+			} else if strings.Contains(invalid.Reason, "\n") {
+				t.Errorf("merge %s in %s mode reason %q spans several lines", tt.file, tt.mode, invalid.Reason)
+			}
+		} else if err != nil {
+			t.Errorf("merge %s in %s mode error = %v", tt.file, tt.mode, err)
+		}
+	}
+}
+
+func TestMergeSkipInvalid(t *testing.T) {
+	result, err := mergeTestPDFs(t, ValidationOptions{Mode: ValidationStrict, SkipInvalid: true},
+		"valid.pdf", "invalid.pdf", "small.pdf", "invalid-date.pdf")
+	if err != nil {
+		t.Fatalf("merge error = %v", err)
+	}
+	if result.MergedFiles != 2 {
+		t.Errorf("merged %d files, want 2", result.MergedFiles)
+	}
+	var skipped []string
+	for _, file := range result.SkippedFiles {
+		skipped = append(skipped, filepath.Base(file.Path))
+		if file.Reason == "" {
+			t.Errorf("skipped %s without a reason", file.Path)
+		}
+	}
+	if want := []string{"invalid.pdf", "invalid-date.pdf"}; !slices.Equal(skipped, want) {
+		t.Errorf("skipped files = %v, want %v", skipped, want)
+	}
+
+	// Nothing is left to merge when every file is skipped
+	if _, err := mergeTestPDFs(t, ValidationOptions{SkipInvalid: true}, "invalid.pdf"); !errors.Is(err, ErrNoValidFiles) {
+		t.Errorf("merge of invalid files only error = %v, want no valid files", err)
+	}
+}
+
+func TestMergeRepair(t *testing.T) {
+	if _, err := mergeTestPDFs(t, ValidationOptions{}, "valid.pdf", "broken-xref-offset.pdf"); err == nil {
+		t.Errorf("merge of a broken file without repair succeeded")
+	}
+
+	result, err := mergeTestPDFs(t, ValidationOptions{Repair: true}, "valid.pdf", "broken-xref-offset.pdf")
+	if err != nil {
+		t.Fatalf("merge with repair error = %v", err)
+	}
+	if result.MergedFiles != 2 {
+		t.Errorf("merged %d files, want 2", result.MergedFiles)
+	}
+	if want := []string{filepath.Join("testdata", "broken-xref-offset.pdf")}; !slices.Equal(result.RepairedFiles, want) {
+		t.Errorf("repaired files = %v, want %v", result.RepairedFiles, want)
+	}
+	if count, err := api.PageCountFile(result.OutputPath); err != nil || count != 2 {
+		t.Errorf("merged file has %d pages, error = %v, want 2", count, err)
+	}
+}